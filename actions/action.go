@@ -16,6 +16,11 @@ type Action interface {
 	Execute(arguments []string)
 }
 
+// ExitCoder is implemented by actions which report their result with the exit code of dotman.
+type ExitCoder interface {
+	ExitCode() int
+}
+
 type ActionInfo struct {
 	name        string
 	description string
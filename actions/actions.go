@@ -9,8 +9,13 @@ import (
 	"github.com/andreaskoch/dotman/actions/changes"
 	"github.com/andreaskoch/dotman/actions/clone"
 	"github.com/andreaskoch/dotman/actions/commit"
+	"github.com/andreaskoch/dotman/actions/convert"
+	"github.com/andreaskoch/dotman/actions/decrypt"
 	"github.com/andreaskoch/dotman/actions/deploy"
+	"github.com/andreaskoch/dotman/actions/encrypt"
+	"github.com/andreaskoch/dotman/actions/format"
 	"github.com/andreaskoch/dotman/actions/importer"
+	"github.com/andreaskoch/dotman/actions/lint"
 	"github.com/andreaskoch/dotman/actions/list"
 	"github.com/andreaskoch/dotman/actions/pull"
 	"github.com/andreaskoch/dotman/actions/push"
@@ -22,6 +27,15 @@ var (
 	availableActions = make([]ActionMetaData, 0)
 )
 
+// Options contains the global settings which apply to all actions.
+type Options struct {
+	// the maximum depth of module directories below the working directory
+	MaxDepth int
+
+	// use modules whose module files contain errors
+	Force bool
+}
+
 func init() {
 
 	// initialize the list of available actions
@@ -35,14 +49,19 @@ func init() {
 		NewActionInfo(commit.ActionName, commit.ActionDescription),
 		NewActionInfo(push.ActionName, push.ActionDescription),
 		NewActionInfo(pull.ActionName, pull.ActionDescription),
+		NewActionInfo(encrypt.ActionName, encrypt.ActionDescription),
+		NewActionInfo(decrypt.ActionName, decrypt.ActionDescription),
+		NewActionInfo(convert.ActionName, convert.ActionDescription),
+		NewActionInfo(lint.ActionName, lint.ActionDescription),
+		NewActionInfo(format.ActionName, format.ActionDescription),
 	}
 }
 
-func Get(workingDirectory string, actionName string) Action {
+func Get(workingDirectory string, actionName string, options Options) Action {
 
 	// create a modules provider for the supplied working directory
 	modulesProvider := func() *modules.Collection {
-		return getModuleCollection(workingDirectory)
+		return getModuleCollection(workingDirectory, options)
 	}
 
 	// detect which action is requested
@@ -75,6 +94,23 @@ func Get(workingDirectory string, actionName string) Action {
 	case pull.ActionName:
 		return pull.New(workingDirectory, modulesProvider)
 
+	case encrypt.ActionName:
+		return encrypt.New(workingDirectory)
+
+	case decrypt.ActionName:
+		return decrypt.New(workingDirectory)
+
+	case convert.ActionName:
+		return convert.New(modulesProvider)
+
+	case format.ActionName:
+		return format.New(modulesProvider)
+
+	case lint.ActionName:
+		return lint.New(func() (*modules.Collection, error) {
+			return modules.Load(workingDirectory, modules.LoadOptions{MaxDepth: options.MaxDepth, IncludeUnsupported: true})
+		})
+
 	default:
 		return nil // no matching found
 
@@ -87,11 +123,13 @@ func GetAll() []ActionMetaData {
 	return availableActions
 }
 
-func getModuleCollection(workingDirectory string) *modules.Collection {
-	moduleCollection, err := modules.Load(workingDirectory)
+func getModuleCollection(workingDirectory string, options Options) *modules.Collection {
+	moduleCollection, err := modules.Load(workingDirectory, modules.LoadOptions{MaxDepth: options.MaxDepth})
 	if err != nil {
 		ui.Fatal("Unable to load modules. %s", err)
 	}
 
+	moduleCollection.Force = options.Force
+
 	return moduleCollection
 }
@@ -0,0 +1,476 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/andreaskoch/dotman/util/crypt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DefaultFormat = "gzip"
+
+	// the name of the archive files (e.g. "2013-11-24_21-03-12.tar.gz")
+	archiveDateLayout = "2006-01-02_15-04-05"
+
+	// the extension which is appended to the name of encrypted archives
+	encryptedExtension = ".enc"
+)
+
+type archiveFormat struct {
+	name      string
+	extension string
+	newWriter func(writer io.Writer) (io.WriteCloser, error)
+	newReader func(reader io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	archiveFormats = []*archiveFormat{
+		&archiveFormat{"gzip", ".tar.gz", newGzipWriter, newGzipReader},
+		&archiveFormat{"xz", ".tar.xz", newXzWriter, newXzReader},
+		&archiveFormat{"tar", ".tar", newPlainWriter, newPlainReader},
+	}
+)
+
+// getArchiveFormatByFilename detects the archive format from the extension of the supplied file.
+func getArchiveFormatByFilename(filename string) *archiveFormat {
+	filename = strings.TrimSuffix(filename, encryptedExtension)
+	for _, format := range archiveFormats {
+		if strings.HasSuffix(filename, format.extension) {
+			return format
+		}
+	}
+
+	return nil
+}
+
+func getArchiveFormat(name string) (*archiveFormat, error) {
+	for _, format := range archiveFormats {
+		if format.name == strings.ToLower(strings.TrimSpace(name)) {
+			return format, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%q is not a supported archive format. Supported formats are: %s.", name, strings.Join(getArchiveFormatNames(), ", "))
+}
+
+func getArchiveFormatNames() []string {
+	names := make([]string, 0, len(archiveFormats))
+	for _, format := range archiveFormats {
+		names = append(names, format.name)
+	}
+
+	return names
+}
+
+func newGzipWriter(writer io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(writer, gzip.BestCompression)
+}
+
+func newGzipReader(reader io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(reader)
+}
+
+func newPlainWriter(writer io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{writer}, nil
+}
+
+func newPlainReader(reader io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(reader), nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// newXzWriter pipes the archive through the xz command
+// because there is no xz encoder in the standard library.
+func newXzWriter(writer io.Writer) (io.WriteCloser, error) {
+	if _, err := exec.LookPath("xz"); err != nil {
+		return nil, fmt.Errorf("The xz format requires the xz command. %s", err)
+	}
+
+	command := exec.Command("xz", "--compress", "--stdout")
+	command.Stdout = writer
+	command.Stderr = os.Stderr
+
+	input, err := command.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+
+	return &commandWriter{command, input}, nil
+}
+
+func newXzReader(reader io.Reader) (io.ReadCloser, error) {
+	if _, err := exec.LookPath("xz"); err != nil {
+		return nil, fmt.Errorf("The xz format requires the xz command. %s", err)
+	}
+
+	command := exec.Command("xz", "--decompress", "--stdout")
+	command.Stdin = reader
+	command.Stderr = os.Stderr
+
+	output, err := command.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := command.Start(); err != nil {
+		return nil, err
+	}
+
+	return &commandReader{command, output}, nil
+}
+
+type commandReader struct {
+	command *exec.Cmd
+	output  io.ReadCloser
+}
+
+func (reader *commandReader) Read(p []byte) (int, error) {
+	return reader.output.Read(p)
+}
+
+func (reader *commandReader) Close() error {
+	reader.output.Close()
+	return reader.command.Wait()
+}
+
+type commandWriter struct {
+	command *exec.Cmd
+	input   io.WriteCloser
+}
+
+func (writer *commandWriter) Write(p []byte) (int, error) {
+	return writer.input.Write(p)
+}
+
+func (writer *commandWriter) Close() error {
+	if err := writer.input.Close(); err != nil {
+		return err
+	}
+
+	return writer.command.Wait()
+}
+
+// getArchiveEntryName returns the name under which the given path is stored in
+// the archive. Paths inside the home directory are stored relative to it so
+// the archive can be restored on another machine.
+func getArchiveEntryName(path, homeDirectory string) string {
+	if relativePath, err := filepath.Rel(homeDirectory, path); err == nil && fs.IsSameOrInside(path, homeDirectory) {
+		return filepath.ToSlash(relativePath)
+	}
+
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
+
+// An archiveEntry is a file, directory or symlink which belongs to a module.
+// The source is the path in the module the entry is mapped from.
+type archiveEntry struct {
+	path   string
+	module string
+	source string
+}
+
+func newArchiveEntry(path, module, source string) *archiveEntry {
+	return &archiveEntry{
+		path:   path,
+		module: module,
+		source: source,
+	}
+}
+
+func createTarArchive(archivePath string, format *archiveFormat, key *crypt.Key, homeDirectory string, entries []*archiveEntry) (success bool, err error) {
+
+	// create the archive writer
+	archive, err := newArchiveWriter(archivePath, format, key, homeDirectory)
+	if err != nil {
+		return false, err
+	}
+
+	// add the files to the archive
+	for _, entry := range entries {
+		if err := archive.Add(entry); err != nil {
+			archive.Abort()
+			return false, err
+		}
+	}
+
+	// finish the archive
+	if err := archive.Close(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// An archiveWriter streams a tar archive to a temporary file next to the
+// archive path and only moves it into place once the archive is complete.
+// If a key is supplied the compressed archive is encrypted.
+type archiveWriter struct {
+	path          string
+	homeDirectory string
+	manifest      *manifest
+
+	file       *os.File
+	buffer     *bufio.Writer
+	encryption io.WriteCloser
+	compressor io.WriteCloser
+	archive    *tar.Writer
+}
+
+func newArchiveWriter(archivePath string, format *archiveFormat, key *crypt.Key, homeDirectory string) (*archiveWriter, error) {
+
+	// create the temporary file
+	file, err := os.CreateTemp(filepath.Dir(archivePath), "."+filepath.Base(archivePath)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bufio.NewWriterSize(file, 64*1024)
+
+	// encrypt the archive
+	var encryption io.WriteCloser = nopWriteCloser{buffer}
+	if key != nil {
+		encryption, err = crypt.NewWriter(buffer, key)
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return nil, err
+		}
+	}
+
+	// compress the archive
+	compressor, err := format.newWriter(encryption)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &archiveWriter{
+		path:          archivePath,
+		homeDirectory: homeDirectory,
+		manifest:      newManifest(),
+
+		file:       file,
+		buffer:     buffer,
+		encryption: encryption,
+		compressor: compressor,
+		archive:    tar.NewWriter(compressor),
+	}, nil
+}
+
+// Add writes the supplied file, directory or symlink to the archive
+// and records it in the manifest.
+func (writer *archiveWriter) Add(entry *archiveEntry) error {
+
+	path := entry.path
+
+	fileInfo, err := os.Lstat(path)
+	if err != nil {
+		return err // unable to get file info
+	}
+
+	file, err := newManifestFile(entry, fileInfo, writer.homeDirectory)
+	if err != nil {
+		return err
+	}
+
+	if file.Type == fileTypeOther {
+		return nil // skip sockets, devices and pipes
+	}
+
+	// create the file header
+	fileHeader, err := tar.FileInfoHeader(fileInfo, file.Link)
+	if err != nil {
+		return fmt.Errorf("Unable to create an archive header for %q. %s", path, err)
+	}
+
+	fileHeader.Name = file.Path
+	if fileInfo.IsDir() {
+		fileHeader.Name += "/"
+	}
+
+	// only regular files have content
+	if file.Type != fileTypeRegular {
+		writer.manifest.Files = append(writer.manifest.Files, file)
+		return writer.archive.WriteHeader(fileHeader)
+	}
+
+	// open the source file
+	fileReader, err := os.Open(path)
+	if err != nil {
+		return err // unable to open target file
+	}
+
+	defer fileReader.Close()
+
+	// write the file header
+	if err := writer.archive.WriteHeader(fileHeader); err != nil {
+		return fmt.Errorf("Unable to write the archive header for %q. %s", path, err)
+	}
+
+	// write the file content and calculate the checksum
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(writer.archive, hash), fileReader); err != nil {
+		return fmt.Errorf("Unable to add %q to the archive. %s", path, err)
+	}
+
+	file.Hash = hex.EncodeToString(hash.Sum(nil))
+	writer.manifest.Files = append(writer.manifest.Files, file)
+
+	return nil
+}
+
+// writeManifest adds the manifest as the last entry of the archive.
+func (writer *archiveWriter) writeManifest() error {
+
+	content, err := writer.manifest.Bytes()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{
+		Name:    manifestEntryName,
+		Mode:    0600,
+		Size:    int64(len(content)),
+		ModTime: writer.manifest.Date,
+	}
+
+	if err := writer.archive.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = writer.archive.Write(content)
+	return err
+}
+
+// Close finishes the archive and moves it to its final location.
+func (writer *archiveWriter) Close() error {
+
+	if err := writer.finish(); err != nil {
+		os.Remove(writer.file.Name())
+		return err
+	}
+
+	return os.Rename(writer.file.Name(), writer.path)
+}
+
+// Abort discards the partially written archive.
+func (writer *archiveWriter) Abort() {
+	writer.compressor.Close()
+	writer.file.Close()
+	os.Remove(writer.file.Name())
+}
+
+func (writer *archiveWriter) finish() error {
+
+	if err := writer.writeManifest(); err != nil {
+		writer.file.Close()
+		return err
+	}
+
+	if err := writer.archive.Close(); err != nil {
+		writer.file.Close()
+		return err
+	}
+
+	if err := writer.compressor.Close(); err != nil {
+		writer.file.Close()
+		return err
+	}
+
+	if err := writer.encryption.Close(); err != nil {
+		writer.file.Close()
+		return err
+	}
+
+	if err := writer.buffer.Flush(); err != nil {
+		writer.file.Close()
+		return err
+	}
+
+	if err := writer.file.Sync(); err != nil {
+		writer.file.Close()
+		return err
+	}
+
+	return writer.file.Close()
+}
+
+// readTarArchive calls the supplied function for every entry of the given archive.
+// Encrypted archives are decrypted with the supplied key.
+func readTarArchive(archivePath string, key *crypt.Key, expression func(header *tar.Header, content io.Reader) error) error {
+
+	format := getArchiveFormatByFilename(archivePath)
+	if format == nil {
+		return fmt.Errorf("%q is not a known archive format.", archivePath)
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	var reader io.Reader = bufio.NewReader(file)
+	if strings.HasSuffix(archivePath, encryptedExtension) {
+		if key == nil {
+			return fmt.Errorf("The archive %q is encrypted.", archivePath)
+		}
+
+		reader, err = crypt.NewReader(reader, key)
+		if err != nil {
+			return fmt.Errorf("Unable to decrypt archive %q. %s", archivePath, err)
+		}
+	}
+
+	decompressor, err := format.newReader(reader)
+	if err != nil {
+		return fmt.Errorf("Unable to read archive %q. %s", archivePath, err)
+	}
+
+	defer decompressor.Close()
+
+	archive := tar.NewReader(decompressor)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("Unable to read archive %q. %s", archivePath, err)
+		}
+
+		if err := expression(header, archive); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
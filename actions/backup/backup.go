@@ -5,15 +5,15 @@
 package backup
 
 import (
-	"archive/tar"
-	"bytes"
+	"flag"
 	"fmt"
 	"github.com/andreaskoch/dotman/actions/base"
 	"github.com/andreaskoch/dotman/ui"
+	"github.com/andreaskoch/dotman/util/crypt"
 	"github.com/andreaskoch/dotman/util/fs"
-	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -21,9 +21,22 @@ const (
 	BackupDirectoryName = ".backup"
 	ActionName          = "backup"
 	ActionDescription   = "Backup your target files."
+
+	listCommandName    = "list"
+	pruneCommandName   = "prune"
+	restoreCommandName = "restore"
+	gcCommandName      = "gc"
+	verifyCommandName  = "verify"
+	decryptCommandName = "decrypt"
+
+	// the available backup backends
+	archiveBackendName = "archive"
+	objectsBackendName = "objects"
 )
 
 type Backup struct {
+	base.SkippedModules
+
 	moduleCollectionProvider base.ModulesProviderFunc
 }
 
@@ -51,37 +64,142 @@ func (backup *Backup) DryRun(arguments []string) {
 
 func (backup *Backup) execute(executeADryRunOnly bool, arguments []string) {
 
+	// detect the sub command
+	if len(arguments) > 0 {
+		switch arguments[0] {
+
+		case listCommandName:
+			backup.list(arguments[1:])
+			return
+
+		case pruneCommandName:
+			backup.prune(executeADryRunOnly, arguments[1:])
+			return
+
+		case restoreCommandName:
+			backup.restore(executeADryRunOnly, arguments[1:])
+			return
+
+		case gcCommandName:
+			backup.gc(executeADryRunOnly, arguments[1:])
+			return
+
+		case verifyCommandName:
+			backup.verify(arguments[1:])
+			return
+
+		case decryptCommandName:
+			backup.decrypt(executeADryRunOnly, arguments[1:])
+			return
+
+		}
+	}
+
+	backup.create(executeADryRunOnly, arguments)
+}
+
+func (backup *Backup) create(executeADryRunOnly bool, arguments []string) {
+
+	// parse the backup options
+	options := flag.NewFlagSet(ActionName, flag.ExitOnError)
+	formatName := options.String("format", DefaultFormat, fmt.Sprintf("The archive format (%s).", strings.Join(getArchiveFormatNames(), ", ")))
+	backendName := options.String("backend", archiveBackendName, fmt.Sprintf("Store the backup as a tar archive (%s) or in the deduplicating object store (%s).", archiveBackendName, objectsBackendName))
+	encrypt := options.Bool("encrypt", false, "Encrypt the archive with a passphrase or key file.")
+	keyFile := options.String("keyfile", "", "The key file used to encrypt the archive (implies -encrypt).")
+	options.Parse(arguments)
+
+	if *backendName != archiveBackendName && *backendName != objectsBackendName {
+		ui.Fatal("%q is not a known backup backend. Available backends are: %s, %s.", *backendName, archiveBackendName, objectsBackendName)
+	}
+
+	if *keyFile != "" {
+		*encrypt = true
+	}
+
+	if *encrypt && *backendName == objectsBackendName {
+		ui.Fatal("Encryption is only available for tar archives.")
+	}
+
+	format, err := getArchiveFormat(*formatName)
+	if err != nil {
+		ui.Fatal("%s", err)
+	}
+
+	// the archive entries are stored relative to the home directory
+	homeDirectory, err := fs.GetUserHomeDirectory()
+	if err != nil {
+		ui.Fatal("Unable to determine the home directory. %s", err)
+	}
+
+	// split the arguments into the module selector and explicit target paths
+	selectorArguments, selectedTargets := getTargetArguments(options.Args(), homeDirectory)
+
 	modules := backup.moduleCollectionProvider()
+	selectedModules, skippedModules := base.SelectModules(modules, selectorArguments)
+	backup.SkippedModules.Add(skippedModules)
 
 	// assemble a list of all files to backup
-	files := make([]string, 0)
-	for _, module := range modules.Collection {
-
-		// add the module file
-		files = append(files, module.ModuleFile())
+	entries := make([]*archiveEntry, 0)
+	addedPaths := make(map[string]bool)
+	coveredTargets := make(map[string]bool)
+	for _, module := range selectedModules {
 
 		// add all target files
+		moduleEntries := make([]*archiveEntry, 0)
 		for _, instruction := range module.Map.GetInstructions() {
 
 			targetPath := instruction.Target()
 
-			if !fs.PathExists(targetPath) {
+			if _, err := os.Lstat(targetPath); err != nil {
 				continue // skip non-existent files
 			}
 
-			if !fs.IsDirectory(targetPath) {
-				files = append(files, targetPath)
-				continue
+			for _, path := range getAllEntries(targetPath) {
+
+				// skip files which have already been added or which have not been selected
+				if addedPaths[path] || !isSelectedPath(path, selectedTargets, coveredTargets) {
+					continue
+				}
+
+				source := instruction.Source()
+				if relativePath, err := filepath.Rel(targetPath, path); err == nil && relativePath != "." {
+
+					// skip the files which are excluded from the entry
+					if instruction.Options().IsExcluded(relativePath) {
+						continue
+					}
+
+					source = filepath.Join(source, relativePath)
+				}
+
+				moduleEntries = append(moduleEntries, newArchiveEntry(path, module.String(), source))
+				addedPaths[path] = true
 			}
 
-			subDirectoryFiles := fs.GetAllFilesRecursively(targetPath)
-			files = append(files, subDirectoryFiles...)
+		}
 
+		// add the module file unless none of the module's targets have been selected
+		if len(selectedTargets) > 0 && len(moduleEntries) == 0 {
+			continue
 		}
+
+		entries = append(entries, newArchiveEntry(module.ModuleFile(), module.String(), module.ModuleFile()))
+		entries = append(entries, moduleEntries...)
+	}
+
+	for _, target := range selectedTargets {
+		if !coveredTargets[target] {
+			ui.Message("%q is not mapped by any of the selected modules.", target)
+		}
+	}
+
+	if len(entries) == 0 {
+		ui.Message("There are no files to backup.")
+		return
 	}
 
 	// make sure the archive directory exists
-	archiveDirectory := filepath.Join(modules.BaseDirectory, BackupDirectoryName)
+	archiveDirectory := getArchiveDirectory(modules.BaseDirectory)
 	if !fs.DirectoryExists(archiveDirectory) {
 		ui.Message("Creating backup directory %q.", archiveDirectory)
 		if !executeADryRunOnly && !fs.CreateDirectory(archiveDirectory) {
@@ -90,14 +208,34 @@ func (backup *Backup) execute(executeADryRunOnly bool, arguments []string) {
 	}
 
 	// assemble a filename for the backup archive
-	const dateLayout = "2006-01-02 15:04:05"
-	filename := fmt.Sprintf("%s.tar", time.Now().Format(dateLayout))
+	filename := fmt.Sprintf("%s%s", time.Now().Format(archiveDateLayout), format.extension)
+	if *encrypt {
+		filename += encryptedExtension
+	}
+
 	archivePath := filepath.Join(archiveDirectory, filename)
+	if *backendName == objectsBackendName {
+		filename = fmt.Sprintf("%s%s", time.Now().Format(archiveDateLayout), snapshotExtension)
+		archivePath = filepath.Join(archiveDirectory, SnapshotsDirectoryName, filename)
+	}
 
 	if !executeADryRunOnly {
 
 		// create the archive
-		_, err := createTarArchive(archivePath, files)
+		if *backendName == objectsBackendName {
+			err = createSnapshot(archivePath, newObjectStore(archiveDirectory), homeDirectory, entries)
+		} else {
+			var key *crypt.Key
+			if *encrypt {
+				key, err = crypt.GetKey(*keyFile, true)
+				if err != nil {
+					ui.Fatal("%s", err)
+				}
+			}
+
+			_, err = createTarArchive(archivePath, format, key, homeDirectory, entries)
+		}
+
 		if err != nil {
 			ui.Fatal("Unable to create a backup %q. %s", archivePath, err)
 		}
@@ -107,69 +245,92 @@ func (backup *Backup) execute(executeADryRunOnly bool, arguments []string) {
 	} else {
 
 		ui.Message("Creating archive %s:", archivePath)
-		for number, file := range files {
-			ui.Message("%d. Adding file %q", (number + 1), file)
+		for number, entry := range entries {
+			ui.Message("%d. Adding %q as %q", (number + 1), entry.path, getArchiveEntryName(entry.path, homeDirectory))
 		}
 
 	}
-}
-
-func createTarArchive(archivePath string, files []string) (success bool, err error) {
 
-	// Create a buffer to write our archive to.
-	buffer := new(bytes.Buffer)
-
-	// create the archive writer
-	archiveWriter, archiveWriterError := os.OpenFile(archivePath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0600)
-	if archiveWriterError != nil {
-		return false, fmt.Errorf("%s", archiveWriterError)
+	// apply the configured retention policy
+	policy, err := readRetentionPolicy(archiveDirectory)
+	if err != nil {
+		ui.Fatal("%s", err)
 	}
 
-	defer func() {
-		archiveWriter.Write(buffer.Bytes())
-		archiveWriter.Close()
-	}()
+	if !policy.IsEmpty() {
+		pruneArchives(archiveDirectory, policy, executeADryRunOnly)
+	}
+}
 
-	// Create a new tar archive.
-	archive := tar.NewWriter(buffer)
+// getTargetArguments separates explicit target paths (arguments starting
+// with "~", "/" or ".") from the module selector arguments.
+func getTargetArguments(arguments []string, homeDirectory string) (selectorArguments []string, targets []string) {
 
-	// Add some files to the archive.
-	for _, file := range files {
+	selectorArguments = make([]string, 0)
+	targets = make([]string, 0)
+	for _, argument := range arguments {
 
-		// open the source file
-		fileReader, readerError := os.Open(file)
-		if readerError != nil {
-			return false, fmt.Errorf("%s", readerError) // unable to open target file
+		if !strings.HasPrefix(argument, "~") && !strings.HasPrefix(argument, "/") && !strings.HasPrefix(argument, ".") {
+			selectorArguments = append(selectorArguments, argument)
+			continue
 		}
 
-		defer fileReader.Close()
-
-		fileInfo, fileInfoError := os.Stat(file)
-		if fileInfoError != nil {
-			return false, fmt.Errorf("%s", fileInfoError) // unable to get file info
+		target := argument
+		if target == "~" || strings.HasPrefix(target, "~/") {
+			target = filepath.Join(homeDirectory, target[1:])
 		}
 
-		// create the file header
-		fileHeader := &tar.Header{
-			Name: file,
-			Size: fileInfo.Size(),
+		if absoluteTarget, err := filepath.Abs(target); err == nil {
+			target = absoluteTarget
 		}
 
-		// write the file header
-		if fileHeaderError := archive.WriteHeader(fileHeader); fileHeaderError != nil {
-			return false, fmt.Errorf("%s", fileHeaderError)
+		targets = append(targets, target)
+	}
+
+	return selectorArguments, targets
+}
+
+// isSelectedPath checks whether the supplied path is one of the selected targets,
+// is located inside of them or is one of their parent directories.
+// All selected targets matching the path are marked as covered.
+func isSelectedPath(path string, selectedTargets []string, coveredTargets map[string]bool) bool {
+
+	if len(selectedTargets) == 0 {
+		return true
+	}
+
+	isSelected := false
+	for _, target := range selectedTargets {
+		if fs.IsSameOrInside(path, target) {
+			coveredTargets[target] = true
+			isSelected = true
+			continue
 		}
 
-		// write the file content
-		if _, copyError := io.Copy(archive, fileReader); copyError != nil {
-			return false, fmt.Errorf("%s", copyError)
+		if fs.IsSameOrInside(target, path) {
+			isSelected = true
 		}
 	}
 
-	// check for errors
-	if closeError := archive.Close(); closeError != nil {
-		return false, fmt.Errorf("%s", closeError)
-	}
+	return isSelected
+}
+
+func getArchiveDirectory(baseDirectory string) string {
+	return filepath.Join(baseDirectory, BackupDirectoryName)
+}
+
+// getAllEntries returns the supplied path and, if it is a directory,
+// all files, directories and symlinks below it. Symlinks are not followed.
+func getAllEntries(path string) []string {
+	entries := make([]string, 0)
+	filepath.Walk(path, func(entryPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // skip unreadable entries
+		}
+
+		entries = append(entries, entryPath)
+		return nil
+	})
 
-	return true, nil
+	return entries
 }
@@ -0,0 +1,90 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"flag"
+	"github.com/andreaskoch/dotman/ui"
+	"github.com/andreaskoch/dotman/util/crypt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"os"
+	"path/filepath"
+)
+
+func (backup *Backup) decrypt(executeADryRunOnly bool, arguments []string) {
+
+	keyFile, arguments := parseKeyFileOption(decryptCommandName, arguments)
+	if len(arguments) < 2 {
+		ui.Message("Please specify the encrypted archive and the path of the decrypted archive.")
+		return
+	}
+
+	modules := backup.moduleCollectionProvider()
+	archiveDirectory := getArchiveDirectory(modules.BaseDirectory)
+
+	archive, err := findArchive(archiveDirectory, arguments[0])
+	if err != nil {
+		ui.Fatal("%s", err)
+	}
+
+	if !archive.isEncrypted {
+		ui.Fatal("The archive %q is not encrypted.", archive)
+	}
+
+	outputPath, err := filepath.Abs(arguments[1])
+	if err != nil {
+		ui.Fatal("%q is not a valid path. %s", arguments[1], err)
+	}
+
+	ui.Message("Decrypting %q to %q.", archive, outputPath)
+	if executeADryRunOnly {
+		return
+	}
+
+	key := getArchiveKey([]*archiveInfo{archive}, keyFile)
+
+	file, err := os.Open(archive.path)
+	if err != nil {
+		ui.Fatal("%s", err)
+	}
+
+	defer file.Close()
+
+	reader, err := crypt.NewReader(file, key)
+	if err != nil {
+		ui.Fatal("Unable to decrypt %q. %s", archive, err)
+	}
+
+	if err := fs.WriteFileAtomically(outputPath, reader, 0600); err != nil {
+		ui.Fatal("Unable to decrypt %q. %s", archive, err)
+	}
+}
+
+// parseKeyFileOption extracts the -keyfile option from the supplied arguments.
+func parseKeyFileOption(commandName string, arguments []string) (keyFile string, remainingArguments []string) {
+	options := flag.NewFlagSet(commandName, flag.ExitOnError)
+	options.StringVar(&keyFile, "keyfile", "", "The key file used to decrypt encrypted archives.")
+	options.Parse(arguments)
+
+	return keyFile, options.Args()
+}
+
+// getArchiveKey returns the key for the supplied archives or nil if none of them is encrypted.
+func getArchiveKey(archives []*archiveInfo, keyFile string) *crypt.Key {
+	for _, archive := range archives {
+		if !archive.isEncrypted {
+			continue
+		}
+
+		key, err := crypt.GetKey(keyFile, false)
+		if err != nil {
+			ui.Fatal("%s", err)
+		}
+
+		return key
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"archive/tar"
+	"fmt"
+	"github.com/andreaskoch/dotman/ui"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// An archiveInfo describes a tar archive or a snapshot in the object store.
+type archiveInfo struct {
+	path string
+	name string
+	date time.Time
+	size int64
+
+	isSnapshot  bool
+	isEncrypted bool
+}
+
+func (archive *archiveInfo) String() string {
+	return archive.name
+}
+
+// readSummary returns the number of files in the archive and the names of the modules they belong to.
+func (archive *archiveInfo) readSummary() (fileCount int, modules []string, err error) {
+
+	moduleNames := make(map[string]bool)
+	if archive.isSnapshot {
+		manifest, err := readManifest(archive.path)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		for _, file := range manifest.Files {
+			if file.Type != fileTypeDirectory {
+				fileCount++
+			}
+
+			moduleNames[file.Module] = true
+		}
+	} else {
+		err = readTarArchive(archive.path, nil, func(header *tar.Header, content io.Reader) error {
+			if header.Name != manifestEntryName {
+				return nil
+			}
+
+			manifestContent, err := ioutil.ReadAll(content)
+			if err != nil {
+				return err
+			}
+
+			archiveManifest, err := parseManifest(archive.path, manifestContent)
+			if err != nil {
+				return err
+			}
+
+			for _, file := range archiveManifest.Files {
+				if file.Type != fileTypeDirectory {
+					fileCount++
+				}
+
+				moduleNames[file.Module] = true
+			}
+
+			return nil
+		})
+	}
+
+	modules = make([]string, 0, len(moduleNames))
+	for module := range moduleNames {
+		modules = append(modules, module)
+	}
+
+	sort.Strings(modules)
+
+	return fileCount, modules, err
+}
+
+// getArchives returns all backup archives in the supplied directory, newest first.
+func getArchives(archiveDirectory string) ([]*archiveInfo, error) {
+
+	archives := make([]*archiveInfo, 0)
+
+	directoryEntries, err := ioutil.ReadDir(archiveDirectory)
+	if err != nil {
+		return archives, nil // no backups yet
+	}
+
+	for _, entry := range directoryEntries {
+
+		// skip directories, temporary files and unknown files
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		format := getArchiveFormatByFilename(entry.Name())
+		if format == nil {
+			continue
+		}
+
+		// determine the backup date from the filename
+		date, err := time.ParseInLocation(archiveDateLayout, strings.TrimSuffix(strings.TrimSuffix(entry.Name(), encryptedExtension), format.extension), time.Local)
+		if err != nil {
+			date = entry.ModTime()
+		}
+
+		archives = append(archives, &archiveInfo{
+			path: filepath.Join(archiveDirectory, entry.Name()),
+			name: entry.Name(),
+			date: date,
+			size: entry.Size(),
+
+			isEncrypted: strings.HasSuffix(entry.Name(), encryptedExtension),
+		})
+	}
+
+	// add the snapshots of the object store
+	snapshots, err := getSnapshots(archiveDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	archives = append(archives, snapshots...)
+
+	sort.Sort(sort.Reverse(archivesByDate(archives)))
+
+	return archives, nil
+}
+
+func getSnapshots(archiveDirectory string) ([]*archiveInfo, error) {
+
+	snapshots := make([]*archiveInfo, 0)
+
+	snapshotDirectory := filepath.Join(archiveDirectory, SnapshotsDirectoryName)
+	directoryEntries, err := ioutil.ReadDir(snapshotDirectory)
+	if err != nil {
+		return snapshots, nil // no snapshots yet
+	}
+
+	for _, entry := range directoryEntries {
+
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !strings.HasSuffix(entry.Name(), snapshotExtension) {
+			continue
+		}
+
+		snapshotPath := filepath.Join(snapshotDirectory, entry.Name())
+		manifest, err := readManifest(snapshotPath)
+		if err != nil {
+			return nil, err
+		}
+
+		// the size of a snapshot is the size of the files it contains
+		size := int64(0)
+		for _, file := range manifest.Files {
+			size += file.Size
+		}
+
+		snapshots = append(snapshots, &archiveInfo{
+			path: snapshotPath,
+			name: path.Join(SnapshotsDirectoryName, entry.Name()),
+			date: manifest.Date,
+			size: size,
+
+			isSnapshot: true,
+		})
+	}
+
+	return snapshots, nil
+}
+
+type archivesByDate []*archiveInfo
+
+func (archives archivesByDate) Len() int           { return len(archives) }
+func (archives archivesByDate) Swap(i, j int)      { archives[i], archives[j] = archives[j], archives[i] }
+func (archives archivesByDate) Less(i, j int) bool { return archives[i].date.Before(archives[j].date) }
+
+func (backup *Backup) list(arguments []string) {
+
+	modules := backup.moduleCollectionProvider()
+	archiveDirectory := getArchiveDirectory(modules.BaseDirectory)
+
+	archives, err := getArchives(archiveDirectory)
+	if err != nil {
+		ui.Fatal("%s", err)
+	}
+
+	if len(archives) == 0 {
+		ui.Message("There are no backups in %q.", archiveDirectory)
+		return
+	}
+
+	totalSize := int64(0)
+	for _, archive := range archives {
+
+		// the content of encrypted archives is not shown
+		if archive.isEncrypted {
+			ui.Message("%s  %s  %9s  (encrypted)", archive, archive.date.Format("2006-01-02 15:04:05"), formatSize(archive.size))
+			totalSize += archive.size
+			continue
+		}
+
+		fileCount, moduleNames, err := archive.readSummary()
+		if err != nil {
+			ui.Message("%s  %s  %9s  %s", archive, archive.date.Format("2006-01-02 15:04:05"), formatSize(archive.size), err)
+			continue
+		}
+
+		ui.Message("%s  %s  %9s  %5d files  %s", archive, archive.date.Format("2006-01-02 15:04:05"), formatSize(archive.size), fileCount, strings.Join(moduleNames, ", "))
+		totalSize += archive.size
+	}
+
+	ui.Message("\n%d backups, %s in total.", len(archives), formatSize(totalSize))
+}
+
+// formatSize returns a human readable representation of the supplied number of bytes.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	divisor, exponent := int64(unit), 0
+	for remainder := size / unit; remainder >= unit; remainder /= unit {
+		divisor *= unit
+		exponent++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(divisor), "KMGTPE"[exponent])
+}
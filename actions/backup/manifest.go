@@ -0,0 +1,111 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const (
+	// the name of the manifest entry at the end of every tar archive
+	manifestEntryName = ".dotman/manifest.json"
+
+	fileTypeRegular   = "file"
+	fileTypeDirectory = "directory"
+	fileTypeSymlink   = "symlink"
+	fileTypeOther     = "other"
+)
+
+// A manifest lists all files of a backup together with
+// the module they belong to and their SHA-256 checksum.
+type manifest struct {
+	Date  time.Time       `json:"date"`
+	Files []*manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	Path    string      `json:"path"`
+	Module  string      `json:"module"`
+	Target  string      `json:"target,omitempty"`
+	Source  string      `json:"source,omitempty"`
+	Type    string      `json:"type"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"modTime"`
+	Size    int64       `json:"size,omitempty"`
+	Hash    string      `json:"hash,omitempty"`
+	Link    string      `json:"link,omitempty"`
+}
+
+func newManifest() *manifest {
+	return &manifest{
+		Date:  time.Now(),
+		Files: make([]*manifestFile, 0),
+	}
+}
+
+// newManifestFile describes the supplied archive entry. The checksum
+// of regular files must be assigned by the caller.
+func newManifestFile(entry *archiveEntry, fileInfo os.FileInfo, homeDirectory string) (*manifestFile, error) {
+
+	file := &manifestFile{
+		Path:    getArchiveEntryName(entry.path, homeDirectory),
+		Module:  entry.module,
+		Target:  entry.path,
+		Source:  entry.source,
+		Mode:    fileInfo.Mode(),
+		ModTime: fileInfo.ModTime(),
+	}
+
+	switch {
+
+	case fileInfo.IsDir():
+		file.Type = fileTypeDirectory
+
+	case fileInfo.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(entry.path)
+		if err != nil {
+			return nil, err
+		}
+
+		file.Type = fileTypeSymlink
+		file.Link = link
+
+	case fileInfo.Mode().IsRegular():
+		file.Type = fileTypeRegular
+		file.Size = fileInfo.Size()
+
+	default:
+		file.Type = fileTypeOther
+
+	}
+
+	return file, nil
+}
+
+func (manifest *manifest) Bytes() ([]byte, error) {
+	return json.MarshalIndent(manifest, "", "\t")
+}
+
+func readManifest(manifestPath string) (*manifest, error) {
+	content, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseManifest(manifestPath, content)
+}
+
+func parseManifest(name string, content []byte) (*manifest, error) {
+	var backupManifest manifest
+	if err := json.Unmarshal(content, &backupManifest); err != nil {
+		return nil, fmt.Errorf("Unable to read the manifest of %q. %s", name, err)
+	}
+
+	return &backupManifest, nil
+}
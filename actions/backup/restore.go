@@ -0,0 +1,301 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"archive/tar"
+	"fmt"
+	"github.com/andreaskoch/dotman/ui"
+	"github.com/andreaskoch/dotman/util/crypt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (backup *Backup) restore(executeADryRunOnly bool, arguments []string) {
+
+	keyFile, arguments := parseKeyFileOption(restoreCommandName, arguments)
+	if len(arguments) == 0 {
+		ui.Message("Please specify the backup you want to restore (see \"%s %s\").", ActionName, listCommandName)
+		return
+	}
+
+	modules := backup.moduleCollectionProvider()
+	archiveDirectory := getArchiveDirectory(modules.BaseDirectory)
+
+	archive, err := findArchive(archiveDirectory, arguments[0])
+	if err != nil {
+		ui.Fatal("%s", err)
+	}
+
+	// restore into the home directory unless another directory is specified
+	destination, err := fs.GetUserHomeDirectory()
+	if err != nil {
+		ui.Fatal("Unable to determine the home directory. %s", err)
+	}
+
+	if len(arguments) > 1 {
+		destination, err = filepath.Abs(arguments[1])
+		if err != nil {
+			ui.Fatal("%q is not a valid directory. %s", arguments[1], err)
+		}
+	}
+
+	ui.Message("Restoring %q to %q.", archive, destination)
+
+	if archive.isSnapshot {
+		err = restoreSnapshot(archive.path, newObjectStore(archiveDirectory), newRestoreDestination(destination), executeADryRunOnly)
+	} else {
+		key := getArchiveKey([]*archiveInfo{archive}, keyFile)
+		err = restoreTarArchive(archive.path, key, newRestoreDestination(destination), executeADryRunOnly)
+	}
+
+	if err != nil {
+		ui.Fatal("Unable to restore %q. %s", archive, err)
+	}
+}
+
+// findArchive returns the archive or snapshot with the supplied name or path.
+func findArchive(archiveDirectory, name string) (*archiveInfo, error) {
+
+	archives, err := getArchives(archiveDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, archive := range archives {
+		if archive.name == name || archive.path == name || filepath.Base(archive.path) == name {
+			return archive, nil
+		}
+
+		if absolutePath, err := filepath.Abs(name); err == nil && absolutePath == archive.path {
+			return archive, nil
+		}
+	}
+
+	return nil, fmt.Errorf("There is no backup named %q in %q.", name, archiveDirectory)
+}
+
+func restoreTarArchive(archivePath string, key *crypt.Key, destination *restoreDestination, executeADryRunOnly bool) error {
+	err := readTarArchive(archivePath, key, func(header *tar.Header, content io.Reader) error {
+
+		if header.Name == manifestEntryName {
+			return nil
+		}
+
+		path, err := destination.getPath(header.Name, header.Typeflag == tar.TypeDir)
+		if err != nil {
+			return err
+		}
+
+		ui.Message("Restoring %s", path)
+		if executeADryRunOnly {
+			return nil
+		}
+
+		switch header.Typeflag {
+
+		case tar.TypeDir:
+			return destination.restoreDirectory(path, header.FileInfo().Mode(), header.ModTime)
+
+		case tar.TypeSymlink:
+			return destination.restoreSymlink(path, header.Linkname)
+
+		case tar.TypeReg:
+			return restoreFile(path, header.FileInfo().Mode(), header.ModTime, content)
+
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return destination.restoreDirectoryAttributes()
+}
+
+func restoreSnapshot(snapshotPath string, store *objectStore, destination *restoreDestination, executeADryRunOnly bool) error {
+
+	manifest, err := readManifest(snapshotPath)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range manifest.Files {
+
+		path, err := destination.getPath(file.Path, file.Type == fileTypeDirectory)
+		if err != nil {
+			return err
+		}
+
+		ui.Message("Restoring %s", path)
+		if executeADryRunOnly {
+			continue
+		}
+
+		switch file.Type {
+
+		case fileTypeDirectory:
+			err = destination.restoreDirectory(path, file.Mode, file.ModTime)
+
+		case fileTypeSymlink:
+			err = destination.restoreSymlink(path, file.Link)
+
+		case fileTypeRegular:
+			object, openError := store.Open(file.Hash)
+			if openError != nil {
+				return fmt.Errorf("The content of %q cannot be read. %s", file.Path, openError)
+			}
+
+			err = restoreFile(path, file.Mode, file.ModTime, object)
+			object.Close()
+
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return destination.restoreDirectoryAttributes()
+}
+
+// A restoreDestination is the directory a backup is restored to. It keeps track of the
+// symbolic links which are restored so that later entries are never written through them,
+// and of the restored directories whose modes and times are applied after their content.
+type restoreDestination struct {
+	directory   string
+	symlinks    map[string]bool
+	directories []*restoredDirectory
+}
+
+// the mode and modification time of a restored directory
+type restoredDirectory struct {
+	path    string
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func newRestoreDestination(directory string) *restoreDestination {
+	return &restoreDestination{
+		directory:   directory,
+		symlinks:    make(map[string]bool),
+		directories: make([]*restoredDirectory, 0),
+	}
+}
+
+// getPath returns the location of the supplied archive entry in the destination directory.
+// Entries must not leave the destination and must not be written through a restored
+// symbolic link (directories are created inside of the path itself, so it is checked too).
+func (destination *restoreDestination) getPath(name string, isDirectory bool) (string, error) {
+
+	path := filepath.Join(destination.directory, filepath.FromSlash(name))
+
+	if !fs.IsSameOrInside(path, destination.directory) {
+		return "", fmt.Errorf("The entry %q points outside of %q.", name, destination.directory)
+	}
+
+	parent := path
+	if !isDirectory {
+		parent = filepath.Dir(path)
+	}
+
+	for ; parent != destination.directory && parent != filepath.Dir(parent); parent = filepath.Dir(parent) {
+		fileInfo, err := os.Lstat(parent)
+		if err != nil || fileInfo.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		if destination.symlinks[parent] {
+			return "", fmt.Errorf("The entry %q would be written through the restored symbolic link %q.", name, parent)
+		}
+	}
+
+	return path, nil
+}
+
+func (destination *restoreDestination) restoreSymlink(path, link string) error {
+	if err := restoreSymlink(path, link); err != nil {
+		return err
+	}
+
+	destination.symlinks[path] = true
+	return nil
+}
+
+// restoreDirectory creates the supplied directory (writable, so its content can be restored).
+// Its mode and modification time are applied by restoreDirectoryAttributes.
+func (destination *restoreDestination) restoreDirectory(path string, mode os.FileMode, modTime time.Time) error {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return err
+	}
+
+	destination.directories = append(destination.directories, &restoredDirectory{path, mode, modTime})
+	return nil
+}
+
+// restoreDirectoryAttributes applies the modes and modification times of the restored directories,
+// the deepest directories first (restoring a file changes the time of its directory and a read-only
+// directory would prevent its sub-directories from being changed).
+func (destination *restoreDestination) restoreDirectoryAttributes() error {
+
+	directories := destination.directories
+	sort.SliceStable(directories, func(i, j int) bool {
+		return strings.Count(directories[i].path, string(os.PathSeparator)) > strings.Count(directories[j].path, string(os.PathSeparator))
+	})
+
+	for _, directory := range directories {
+		if err := os.Chmod(directory.path, directory.mode.Perm()); err != nil {
+			return err
+		}
+
+		if err := os.Chtimes(directory.path, directory.modTime, directory.modTime); err != nil {
+			return err
+		}
+	}
+
+	destination.directories = make([]*restoredDirectory, 0)
+	return nil
+}
+
+func restoreSymlink(path, link string) error {
+	if !fs.CreateDirectory(filepath.Dir(path)) {
+		return fmt.Errorf("Unable to create the directory for %q.", path)
+	}
+
+	// replace existing files
+	if _, err := os.Lstat(path); err == nil {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return os.Symlink(link, path)
+}
+
+func restoreFile(path string, mode os.FileMode, modTime time.Time, content io.Reader) error {
+	if !fs.CreateDirectory(filepath.Dir(path)) {
+		return fmt.Errorf("Unable to create the directory for %q.", path)
+	}
+
+	// replace symbolic links instead of writing through them
+	if fileInfo, err := os.Lstat(path); err == nil && fileInfo.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	if err := fs.WriteFileAtomically(path, content, mode); err != nil {
+		return err
+	}
+
+	return os.Chtimes(path, modTime, modTime)
+}
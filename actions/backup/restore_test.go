@@ -0,0 +1,164 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestoreSnapshotDirectoryAttributes(t *testing.T) {
+
+	directory := t.TempDir()
+	store := newObjectStore(filepath.Join(directory, "objects"))
+
+	contentPath := filepath.Join(directory, "content")
+	if err := ioutil.WriteFile(contentPath, []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := store.Save(contentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	directoryTime := time.Date(2013, 3, 1, 12, 0, 0, 0, time.UTC)
+	fileTime := time.Date(2013, 3, 2, 12, 0, 0, 0, time.UTC)
+	snapshot := &manifest{
+		Date: time.Now(),
+		Files: []*manifestFile{
+			{Path: "read-only", Type: fileTypeDirectory, Mode: os.ModeDir | 0500, ModTime: directoryTime},
+			{Path: "read-only/sub", Type: fileTypeDirectory, Mode: os.ModeDir | 0500, ModTime: directoryTime},
+			{Path: "read-only/sub/file", Type: fileTypeRegular, Mode: 0600, ModTime: fileTime, Hash: hash},
+			{Path: "read-only/file", Type: fileTypeRegular, Mode: 0400, ModTime: fileTime, Hash: hash},
+		},
+	}
+
+	snapshotPath := filepath.Join(directory, "snapshot.json")
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(snapshotPath, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	destination := filepath.Join(directory, "destination")
+	t.Cleanup(func() {
+		os.Chmod(filepath.Join(destination, "read-only", "sub"), 0700)
+		os.Chmod(filepath.Join(destination, "read-only"), 0700)
+	})
+
+	if err := restoreSnapshot(snapshotPath, store, newRestoreDestination(destination), false); err != nil {
+		t.Fatalf("restoreSnapshot failed: %s", err)
+	}
+
+	for _, name := range []string{"read-only/sub/file", "read-only/file"} {
+		if restoredContent, err := ioutil.ReadFile(filepath.Join(destination, name)); err != nil || string(restoredContent) != "content" {
+			t.Errorf("%q contains %q (%v), expected %q", name, restoredContent, err, "content")
+		}
+	}
+
+	for _, name := range []string{"read-only", "read-only/sub"} {
+		fileInfo, err := os.Stat(filepath.Join(destination, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fileInfo.Mode().Perm() != 0500 {
+			t.Errorf("%q has the mode %04o, expected %04o", name, fileInfo.Mode().Perm(), 0500)
+		}
+
+		if !fileInfo.ModTime().Equal(directoryTime) {
+			t.Errorf("%q has the modification time %s, expected %s", name, fileInfo.ModTime(), directoryTime)
+		}
+	}
+}
+
+func TestRestoreSnapshotKeepsTargetsOfCorruptObjects(t *testing.T) {
+
+	directory := t.TempDir()
+	store := newObjectStore(filepath.Join(directory, "objects"))
+
+	contentPath := filepath.Join(directory, "content")
+	if err := ioutil.WriteFile(contentPath, []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := store.Save(contentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objectPath, _ := store.objectPath(hash)
+	if err := ioutil.WriteFile(objectPath, []byte("corrupt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	destination := filepath.Join(directory, "destination")
+	targetPath := filepath.Join(destination, "file")
+	if err := os.MkdirAll(destination, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(targetPath, []byte("current"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []*manifestFile{
+		{Path: "file", Type: fileTypeRegular, Mode: 0600, Hash: hash},
+		{Path: "file", Type: fileTypeRegular, Mode: 0600, Hash: "../../content"},
+	} {
+		snapshotPath := filepath.Join(directory, "snapshot.json")
+		content, err := json.Marshal(&manifest{Date: time.Now(), Files: []*manifestFile{file}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(snapshotPath, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := restoreSnapshot(snapshotPath, store, newRestoreDestination(destination), false); err == nil {
+			t.Errorf("Restoring the object %q succeeded, expected an error", file.Hash)
+		}
+
+		if targetContent, err := ioutil.ReadFile(targetPath); err != nil || string(targetContent) != "current" {
+			t.Errorf("Restoring the object %q changed the target to %q (%v)", file.Hash, targetContent, err)
+		}
+	}
+}
+
+func TestRestoreFileReplacesSymbolicLinks(t *testing.T) {
+
+	directory := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(outside, []byte("outside"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(directory, "file")
+	if err := os.Symlink(outside, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restoreFile(path, 0600, time.Now(), strings.NewReader("restored")); err != nil {
+		t.Fatalf("restoreFile(%q) failed: %s", path, err)
+	}
+
+	if content, err := ioutil.ReadFile(outside); err != nil || string(content) != "outside" {
+		t.Errorf("restoreFile(%q) changed the file the link points to (%q, %v)", path, content, err)
+	}
+
+	if fileInfo, err := os.Lstat(path); err != nil || !fileInfo.Mode().IsRegular() {
+		t.Errorf("restoreFile(%q) did not replace the symbolic link (%v, %v)", path, fileInfo, err)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"flag"
+	"fmt"
+	"github.com/andreaskoch/dotman/ui"
+	"github.com/andreaskoch/dotman/util/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// the name of the file in the backup directory which contains the retention policy
+	RetentionFileName = "retention"
+)
+
+var (
+	// the white space between the name and value of a retention setting
+	retentionSettingSeparatorPattern = regexp.MustCompile(`\s+`)
+
+	// a size such as "500M", "2G" or "1GiB" (in upper case)
+	sizePattern = regexp.MustCompile(`^(\d+)\s*([KMGT]?)I?B?$`)
+)
+
+// A retentionPolicy decides which backup archives are kept.
+// An archive is kept if any of the keep-rules selects it and
+// the total size of the kept archives does not exceed the maximum size.
+type retentionPolicy struct {
+	keepLast   int
+	keepDaily  int
+	keepWeekly int
+	maxSize    int64
+}
+
+func (policy *retentionPolicy) IsEmpty() bool {
+	return policy.keepLast == 0 && policy.keepDaily == 0 && policy.keepWeekly == 0 && policy.maxSize == 0
+}
+
+func (policy *retentionPolicy) hasKeepRules() bool {
+	return policy.keepLast > 0 || policy.keepDaily > 0 || policy.keepWeekly > 0
+}
+
+func (policy *retentionPolicy) String() string {
+	settings := make([]string, 0)
+
+	if policy.keepLast > 0 {
+		settings = append(settings, fmt.Sprintf("keep-last %d", policy.keepLast))
+	}
+
+	if policy.keepDaily > 0 {
+		settings = append(settings, fmt.Sprintf("keep-daily %d", policy.keepDaily))
+	}
+
+	if policy.keepWeekly > 0 {
+		settings = append(settings, fmt.Sprintf("keep-weekly %d", policy.keepWeekly))
+	}
+
+	if policy.maxSize > 0 {
+		settings = append(settings, fmt.Sprintf("max-size %s", formatSize(policy.maxSize)))
+	}
+
+	return strings.Join(settings, ", ")
+}
+
+// set assigns the supplied value to the retention setting with the given name.
+func (policy *retentionPolicy) set(name, value string) error {
+
+	if name == "max-size" {
+		size, err := parseSize(value)
+		if err != nil {
+			return err
+		}
+
+		policy.maxSize = size
+		return nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return fmt.Errorf("%q is not a valid value for %q.", value, name)
+	}
+
+	switch name {
+	case "keep-last":
+		policy.keepLast = number
+	case "keep-daily":
+		policy.keepDaily = number
+	case "keep-weekly":
+		policy.keepWeekly = number
+	default:
+		return fmt.Errorf("%q is not a known retention setting.", name)
+	}
+
+	return nil
+}
+
+// apply splits the supplied archives (newest first) into the ones to keep and the ones to remove.
+// The daily and weekly rules count the days and weeks which have archives, not calendar days
+// and weeks, so a policy never removes the only archives of a long period without backups.
+func (policy *retentionPolicy) apply(archives []*archiveInfo) (keep, remove []*archiveInfo) {
+
+	selected := make(map[*archiveInfo]bool)
+
+	// without keep-rules all archives are subject to the size limit only
+	if !policy.hasKeepRules() {
+		for _, archive := range archives {
+			selected[archive] = true
+		}
+	}
+
+	// keep the last n archives
+	for index, archive := range archives {
+		if index < policy.keepLast {
+			selected[archive] = true
+		}
+	}
+
+	// keep the newest archive of the last n days and weeks which have archives
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for _, archive := range archives {
+
+		day := archive.date.Format("2006-01-02")
+		if !days[day] && len(days) < policy.keepDaily {
+			days[day] = true
+			selected[archive] = true
+		}
+
+		year, week := archive.date.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+		if !weeks[weekKey] && len(weeks) < policy.keepWeekly {
+			weeks[weekKey] = true
+			selected[archive] = true
+		}
+	}
+
+	// enforce the size limit (the newest archive is always kept)
+	totalSize := int64(0)
+	keep = make([]*archiveInfo, 0)
+	remove = make([]*archiveInfo, 0)
+	for _, archive := range archives {
+
+		if !selected[archive] {
+			remove = append(remove, archive)
+			continue
+		}
+
+		if policy.maxSize > 0 && len(keep) > 0 && totalSize+archive.size > policy.maxSize {
+			remove = append(remove, archive)
+			continue
+		}
+
+		totalSize += archive.size
+		keep = append(keep, archive)
+	}
+
+	return keep, remove
+}
+
+// readRetentionPolicy reads the retention policy from the retention file in the supplied backup directory.
+// The file contains one setting per line (e.g. "keep-last 10" or "max-size 500M").
+func readRetentionPolicy(archiveDirectory string) (*retentionPolicy, error) {
+
+	policy := &retentionPolicy{}
+
+	retentionFile := filepath.Join(archiveDirectory, RetentionFileName)
+	if !fs.FileExists(retentionFile) {
+		return policy, nil
+	}
+
+	file, err := os.Open(retentionFile)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	for lineNumber, line := range fs.GetLines(file) {
+
+		// ignore white space and comments
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		setting := retentionSettingSeparatorPattern.Split(line, 2)
+		if len(setting) != 2 {
+			return nil, fmt.Errorf("%s: Line %d: %q is not a valid retention setting.", retentionFile, lineNumber+1, line)
+		}
+
+		if err := policy.set(setting[0], setting[1]); err != nil {
+			return nil, fmt.Errorf("%s: Line %d: %s", retentionFile, lineNumber+1, err)
+		}
+	}
+
+	return policy, nil
+}
+
+// parseSize converts sizes such as "500M" or "2G" into bytes.
+func parseSize(text string) (int64, error) {
+
+	matches := sizePattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(text)))
+	if matches == nil {
+		return 0, fmt.Errorf("%q is not a valid size.", text)
+	}
+
+	size, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid size.", text)
+	}
+
+	for _, unit := range "KMGT" {
+		if matches[2] == "" {
+			break
+		}
+
+		size *= 1024
+		if matches[2] == string(unit) {
+			break
+		}
+	}
+
+	return size, nil
+}
+
+func (backup *Backup) prune(executeADryRunOnly bool, arguments []string) {
+
+	modules := backup.moduleCollectionProvider()
+	archiveDirectory := getArchiveDirectory(modules.BaseDirectory)
+
+	policy, err := readRetentionPolicy(archiveDirectory)
+	if err != nil {
+		ui.Fatal("%s", err)
+	}
+
+	// the command line options override the configured policy
+	options := flag.NewFlagSet(pruneCommandName, flag.ExitOnError)
+	settings := map[string]*string{
+		"keep-last":   options.String("keep-last", "", "Keep the last n backups."),
+		"keep-daily":  options.String("keep-daily", "", "Keep the newest backup of each of the last n days with backups."),
+		"keep-weekly": options.String("keep-weekly", "", "Keep the newest backup of each of the last n weeks with backups."),
+		"max-size":    options.String("max-size", "", "The maximum total size of all backups (e.g. 500M)."),
+	}
+
+	options.Parse(arguments)
+
+	for name, value := range settings {
+		if *value == "" {
+			continue
+		}
+
+		if err := policy.set(name, *value); err != nil {
+			ui.Fatal("%s", err)
+		}
+	}
+
+	if policy.IsEmpty() {
+		ui.Message("No retention policy configured. Add settings such as \"keep-last 10\" to %q or use the -keep-last, -keep-daily, -keep-weekly and -max-size options.", filepath.Join(archiveDirectory, RetentionFileName))
+		return
+	}
+
+	pruneArchives(archiveDirectory, policy, executeADryRunOnly)
+}
+
+// pruneArchives removes all archives from the supplied directory which are not kept by the given policy.
+func pruneArchives(archiveDirectory string, policy *retentionPolicy, executeADryRunOnly bool) {
+
+	archives, err := getArchives(archiveDirectory)
+	if err != nil {
+		ui.Fatal("%s", err)
+	}
+
+	_, remove := policy.apply(archives)
+	if len(remove) == 0 {
+		return
+	}
+
+	ui.Message("Pruning backups (%s):", policy)
+	removedSnapshots := false
+	for _, archive := range remove {
+
+		ui.Message("Removing %q", archive.path)
+		removedSnapshots = removedSnapshots || archive.isSnapshot
+		if executeADryRunOnly {
+			continue
+		}
+
+		if err := os.Remove(archive.path); err != nil {
+			ui.Message("Unable to remove %q. %s", archive.path, err)
+		}
+	}
+
+	// remove the objects which are no longer referenced
+	if removedSnapshots && !executeADryRunOnly {
+		collectGarbage(archiveDirectory, executeADryRunOnly)
+	}
+}
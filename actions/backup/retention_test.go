@@ -0,0 +1,125 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestArchives returns archives (newest first) with the supplied names ("2013-11-24_21")
+// which are the date and hour of the backup and a size of 100 bytes each.
+func newTestArchives(t *testing.T, names ...string) []*archiveInfo {
+	archives := make([]*archiveInfo, 0, len(names))
+	for _, name := range names {
+		date, err := time.Parse("2006-01-02_15", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		archives = append(archives, &archiveInfo{path: name, name: name, date: date, size: 100})
+	}
+
+	return archives
+}
+
+func getArchiveNames(archives []*archiveInfo) string {
+	names := make([]string, 0, len(archives))
+	for _, archive := range archives {
+		names = append(names, archive.name)
+	}
+
+	return strings.Join(names, " ")
+}
+
+func TestRetentionPolicyApply(t *testing.T) {
+
+	// Sunday 2013-11-24 back to Monday 2013-11-11 (two ISO weeks) with a gap of four days
+	archives := newTestArchives(t,
+		"2013-11-24_21", "2013-11-24_09", "2013-11-23_12", "2013-11-18_12",
+		"2013-11-17_20", "2013-11-17_08", "2013-11-12_12", "2013-11-11_12",
+	)
+
+	tests := []struct {
+		policy retentionPolicy
+		kept   string
+	}{
+		{retentionPolicy{}, "2013-11-24_21 2013-11-24_09 2013-11-23_12 2013-11-18_12 2013-11-17_20 2013-11-17_08 2013-11-12_12 2013-11-11_12"},
+		{retentionPolicy{keepLast: 3}, "2013-11-24_21 2013-11-24_09 2013-11-23_12"},
+		{retentionPolicy{keepLast: 20}, "2013-11-24_21 2013-11-24_09 2013-11-23_12 2013-11-18_12 2013-11-17_20 2013-11-17_08 2013-11-12_12 2013-11-11_12"},
+
+		// the days and weeks without archives don't count
+		{retentionPolicy{keepDaily: 3}, "2013-11-24_21 2013-11-23_12 2013-11-18_12"},
+		{retentionPolicy{keepWeekly: 2}, "2013-11-24_21 2013-11-17_20"},
+		{retentionPolicy{keepWeekly: 5}, "2013-11-24_21 2013-11-17_20"},
+		{retentionPolicy{keepLast: 1, keepDaily: 2, keepWeekly: 3}, "2013-11-24_21 2013-11-23_12 2013-11-17_20"},
+
+		// the size limit removes the oldest archives but always keeps the newest one
+		{retentionPolicy{maxSize: 250}, "2013-11-24_21 2013-11-24_09"},
+		{retentionPolicy{maxSize: 50}, "2013-11-24_21"},
+		{retentionPolicy{keepDaily: 4, maxSize: 300}, "2013-11-24_21 2013-11-23_12 2013-11-18_12"},
+	}
+
+	for _, test := range tests {
+		keep, remove := test.policy.apply(archives)
+
+		if kept := getArchiveNames(keep); kept != test.kept {
+			t.Errorf("apply(%s) keeps %q, expected %q", &test.policy, kept, test.kept)
+		}
+
+		if len(keep)+len(remove) != len(archives) {
+			t.Errorf("apply(%s) keeps %d and removes %d of %d archives", &test.policy, len(keep), len(remove), len(archives))
+		}
+	}
+}
+
+func TestRetentionPolicySet(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"keep-last", "10", true},
+		{"keep-last", "0", true},
+		{"keep-last", "-1", false},
+		{"keep-daily", "-7", false},
+		{"keep-weekly", "four", false},
+		{"keep-monthly", "1", false},
+		{"max-size", "500M", true},
+		{"max-size", "2GiB", true},
+		{"max-size", "-1G", false},
+		{"max-size", "lots", false},
+	}
+
+	for _, test := range tests {
+		policy := &retentionPolicy{}
+		if err := policy.set(test.name, test.value); (err == nil) != test.valid {
+			t.Errorf("set(%q, %q) returned the error %v, expected valid=%v", test.name, test.value, err, test.valid)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+
+	tests := []struct {
+		text string
+		size int64
+	}{
+		{"100", 100},
+		{"1K", 1024},
+		{"500M", 500 * 1024 * 1024},
+		{"2g", 2 * 1024 * 1024 * 1024},
+		{"1TB", 1024 * 1024 * 1024 * 1024},
+		{"3 MiB", 3 * 1024 * 1024},
+	}
+
+	for _, test := range tests {
+		if size, err := parseSize(test.text); err != nil || size != test.size {
+			t.Errorf("parseSize(%q) = %d, %v, expected %d", test.text, size, err, test.size)
+		}
+	}
+}
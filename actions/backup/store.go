@@ -0,0 +1,298 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/andreaskoch/dotman/ui"
+	"github.com/andreaskoch/dotman/util/fs"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// the directory inside the backup directory which holds the file contents
+	ObjectsDirectoryName = "objects"
+
+	// the directory inside the backup directory which holds the snapshot manifests
+	SnapshotsDirectoryName = "snapshots"
+
+	snapshotExtension = ".json"
+)
+
+var (
+	// the hex-encoded SHA-256 hash of an object
+	objectHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// An objectStore saves file contents by their SHA-256 hash.
+type objectStore struct {
+	directory string
+}
+
+func newObjectStore(archiveDirectory string) *objectStore {
+	return &objectStore{
+		directory: filepath.Join(archiveDirectory, ObjectsDirectoryName),
+	}
+}
+
+// objectPath returns the location of the object with the supplied hash. Hashes are
+// read from manifests, so they are checked before they become a path.
+func (store *objectStore) objectPath(hash string) (string, error) {
+	if !objectHashPattern.MatchString(hash) {
+		return "", fmt.Errorf("%q is not a valid object hash.", hash)
+	}
+
+	return filepath.Join(store.directory, hash[:2], hash[2:]), nil
+}
+
+// Open returns the content of the object with the supplied hash. Reading the end of the
+// content returns an error if the content doesn't match the hash.
+func (store *objectStore) Open(hash string) (io.ReadCloser, error) {
+	objectPath, err := store.objectPath(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(objectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &verifyingReader{file, sha256.New(), hash}, nil
+}
+
+// A verifyingReader compares the hash of the content it has read with the expected hash.
+type verifyingReader struct {
+	file         *os.File
+	hash         hash.Hash
+	expectedHash string
+}
+
+func (reader *verifyingReader) Read(buffer []byte) (int, error) {
+	n, err := reader.file.Read(buffer)
+	reader.hash.Write(buffer[:n])
+
+	if err == io.EOF && hex.EncodeToString(reader.hash.Sum(nil)) != reader.expectedHash {
+		return n, fmt.Errorf("The object %s is corrupt (its content doesn't match its hash).", reader.expectedHash)
+	}
+
+	return n, err
+}
+
+func (reader *verifyingReader) Close() error {
+	return reader.file.Close()
+}
+
+// Save stores the content of the supplied file unless an object
+// with the same hash already exists and returns the hash.
+// The content is hashed while it is copied, so the hash always belongs
+// to the stored content even if the file changes in the meantime.
+func (store *objectStore) Save(path string) (string, error) {
+
+	source, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer source.Close()
+
+	if !fs.CreateDirectory(store.directory) {
+		return "", fmt.Errorf("Unable to create the object directory %q.", store.directory)
+	}
+
+	// write the content to a temporary object first (hidden objects are ignored)
+	file, err := os.CreateTemp(store.directory, ".object.*.tmp")
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), source); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("Unable to store %q. %s", path, err)
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	// move the temporary object to the location of its hash
+	hashValue := hex.EncodeToString(hash.Sum(nil))
+	objectPath, err := store.objectPath(hashValue)
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	if fs.FileExists(objectPath) {
+		os.Remove(file.Name())
+		return hashValue, nil // already stored
+	}
+
+	if !fs.CreateDirectory(filepath.Dir(objectPath)) {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("Unable to create the object directory for %q.", objectPath)
+	}
+
+	if err := os.Rename(file.Name(), objectPath); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("Unable to store %q. %s", path, err)
+	}
+
+	return hashValue, nil
+}
+
+// Hashes returns the hashes of all stored objects.
+func (store *objectStore) Hashes() []string {
+	hashes := make([]string, 0)
+	for _, objectPath := range fs.GetAllFilesRecursively(store.directory) {
+		relativePath, err := filepath.Rel(store.directory, objectPath)
+		if err != nil || strings.HasPrefix(filepath.Base(objectPath), ".") {
+			continue
+		}
+
+		// skip the files which are not objects
+		if hash := strings.Replace(relativePath, string(os.PathSeparator), "", -1); objectHashPattern.MatchString(hash) {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	return hashes
+}
+
+func getSha256Hash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// createSnapshot stores the contents of the supplied entries in the object
+// store and writes a snapshot manifest to the given path.
+func createSnapshot(snapshotPath string, store *objectStore, homeDirectory string, entries []*archiveEntry) error {
+
+	manifest := newManifest()
+
+	for _, entry := range entries {
+
+		fileInfo, err := os.Lstat(entry.path)
+		if err != nil {
+			return err
+		}
+
+		file, err := newManifestFile(entry, fileInfo, homeDirectory)
+		if err != nil {
+			return err
+		}
+
+		switch file.Type {
+
+		case fileTypeRegular:
+			hash, err := store.Save(entry.path)
+			if err != nil {
+				return err
+			}
+
+			file.Hash = hash
+
+		case fileTypeOther:
+			continue // skip sockets, devices and pipes
+
+		}
+
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	content, err := manifest.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if !fs.CreateDirectory(filepath.Dir(snapshotPath)) {
+		return fmt.Errorf("Unable to create the snapshot directory for %q.", snapshotPath)
+	}
+
+	return fs.WriteFileAtomically(snapshotPath, bytes.NewReader(content), 0600)
+}
+
+// collectGarbage removes all objects which are not referenced by any snapshot.
+func collectGarbage(archiveDirectory string, executeADryRunOnly bool) {
+
+	// determine the referenced objects
+	referencedObjects := make(map[string]bool)
+	snapshots := fs.GetAllFilesRecursively(filepath.Join(archiveDirectory, SnapshotsDirectoryName))
+	for _, snapshotPath := range snapshots {
+		if !strings.HasSuffix(snapshotPath, snapshotExtension) {
+			continue
+		}
+
+		manifest, err := readManifest(snapshotPath)
+		if err != nil {
+			ui.Fatal("%s", err) // never remove objects which might still be needed
+		}
+
+		for _, file := range manifest.Files {
+			if file.Hash != "" {
+				referencedObjects[file.Hash] = true
+			}
+		}
+	}
+
+	// remove all unreferenced objects
+	store := newObjectStore(archiveDirectory)
+	removedObjects := 0
+	for _, hash := range store.Hashes() {
+		if referencedObjects[hash] {
+			continue
+		}
+
+		removedObjects++
+		if executeADryRunOnly {
+			ui.Message("Removing unreferenced object %s", hash)
+			continue
+		}
+
+		objectPath, err := store.objectPath(hash)
+		if err == nil {
+			err = os.Remove(objectPath)
+		}
+
+		if err != nil {
+			ui.Message("Unable to remove object %s. %s", hash, err)
+		}
+	}
+
+	ui.Message("Removed %d unreferenced objects.", removedObjects)
+}
+
+func (backup *Backup) gc(executeADryRunOnly bool, arguments []string) {
+	modules := backup.moduleCollectionProvider()
+	collectGarbage(getArchiveDirectory(modules.BaseDirectory), executeADryRunOnly)
+}
@@ -0,0 +1,154 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestObjectStoreSave(t *testing.T) {
+
+	directory := t.TempDir()
+	path := filepath.Join(directory, "file")
+	content := []byte("some content")
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newObjectStore(filepath.Join(directory, "backup"))
+	hash, err := store.Save(path)
+	if err != nil {
+		t.Fatalf("Save(%q) failed: %s", path, err)
+	}
+
+	expectedHash := sha256.Sum256(content)
+	if hash != hex.EncodeToString(expectedHash[:]) {
+		t.Errorf("Save(%q) = %q, expected the SHA-256 hash of the content", path, hash)
+	}
+
+	objectPath, err := store.objectPath(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storedContent, err := ioutil.ReadFile(objectPath)
+	if err != nil || string(storedContent) != string(content) {
+		t.Errorf("The object %q contains %q (%v), expected %q", hash, storedContent, err, content)
+	}
+
+	// saving the same content again keeps the object and leaves no temporary files
+	if secondHash, err := store.Save(path); err != nil || secondHash != hash {
+		t.Errorf("Save(%q) = %q, %v the second time, expected %q", path, secondHash, err, hash)
+	}
+
+	if hashes := store.Hashes(); len(hashes) != 1 || hashes[0] != hash {
+		t.Errorf("Hashes() = %v, expected [%s]", hashes, hash)
+	}
+
+	temporaryFiles, _ := filepath.Glob(filepath.Join(store.directory, ".*"))
+	if len(temporaryFiles) > 0 {
+		t.Errorf("Save left temporary files: %v", temporaryFiles)
+	}
+}
+
+func TestObjectStoreOpen(t *testing.T) {
+
+	directory := t.TempDir()
+	path := filepath.Join(directory, "file")
+	if err := ioutil.WriteFile(path, []byte("some content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newObjectStore(filepath.Join(directory, "backup"))
+	hash, err := store.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invalidHash := range []string{"", "a", "../../x", strings.ToUpper(hash), hash[:63], hash + "0", "../" + hash[3:]} {
+		if _, err := store.Open(invalidHash); err == nil {
+			t.Errorf("Open(%q) succeeded, expected an error", invalidHash)
+		}
+	}
+
+	object, err := store.Open(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := ioutil.ReadAll(object); err != nil || string(content) != "some content" {
+		t.Errorf("Open(%q) returned %q, %v, expected %q", hash, content, err, "some content")
+	}
+
+	object.Close()
+
+	// a modified object is detected when it is read
+	objectPath, _ := store.objectPath(hash)
+	os.Chmod(objectPath, 0600)
+	if err := ioutil.WriteFile(objectPath, []byte("other content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	object, err = store.Open(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ioutil.ReadAll(object); err == nil {
+		t.Errorf("Reading the modified object %q succeeded, expected an error", hash)
+	}
+
+	object.Close()
+}
+
+func TestRestoreDestinationGetPath(t *testing.T) {
+
+	directory := t.TempDir()
+	outside := t.TempDir()
+	destination := newRestoreDestination(directory)
+
+	// a symbolic link which already exists in the destination
+	if err := os.Symlink(outside, filepath.Join(directory, "existing")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a symbolic link which is created by the restore
+	if err := destination.restoreSymlink(filepath.Join(directory, "restored"), outside); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		isDirectory bool
+		expectError bool
+	}{
+		{"file", false, false},
+		{"a/b/file", false, false},
+		{"../file", false, true},
+		{"a/../../file", false, true},
+		{"existing/file", false, false},
+		{"restored", false, false},
+		{"restored", true, true},
+		{"restored/file", false, true},
+		{"restored/a/file", false, true},
+	}
+
+	for _, test := range tests {
+		path, err := destination.getPath(test.name, test.isDirectory)
+		if test.expectError && err == nil {
+			t.Errorf("getPath(%q, %v) = %q, expected an error", test.name, test.isDirectory, path)
+		}
+
+		if !test.expectError && err != nil {
+			t.Errorf("getPath(%q, %v) failed: %s", test.name, test.isDirectory, err)
+		}
+	}
+}
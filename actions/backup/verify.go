@@ -0,0 +1,200 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/andreaskoch/dotman/ui"
+	"github.com/andreaskoch/dotman/util/crypt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+func (backup *Backup) verify(arguments []string) {
+
+	keyFile, arguments := parseKeyFileOption(verifyCommandName, arguments)
+
+	modules := backup.moduleCollectionProvider()
+	archiveDirectory := getArchiveDirectory(modules.BaseDirectory)
+
+	// verify the supplied archive or all archives
+	archives := make([]*archiveInfo, 0)
+	if len(arguments) > 0 {
+		archive, err := findArchive(archiveDirectory, arguments[0])
+		if err != nil {
+			ui.Fatal("%s", err)
+		}
+
+		archives = append(archives, archive)
+	} else {
+		allArchives, err := getArchives(archiveDirectory)
+		if err != nil {
+			ui.Fatal("%s", err)
+		}
+
+		archives = append(archives, allArchives...)
+	}
+
+	if len(archives) == 0 {
+		ui.Message("There are no backups in %q.", archiveDirectory)
+		return
+	}
+
+	key := getArchiveKey(archives, keyFile)
+
+	invalidArchives := 0
+	for _, archive := range archives {
+
+		var problems []string
+		switch {
+
+		case archive.isSnapshot:
+			problems = verifySnapshot(archive.path, newObjectStore(archiveDirectory))
+
+		// archives which have been encrypted with another key are not corrupt
+		case archive.isEncrypted && !keyMatches(archive.path, key):
+			problems = []string{"The key does not match. The backup has been encrypted with another key (or its beginning has been modified)."}
+
+		default:
+			problems = verifyTarArchive(archive.path, key)
+
+		}
+
+		if len(problems) == 0 {
+			ui.Message("%s: OK", archive)
+			continue
+		}
+
+		invalidArchives++
+		ui.Message("%s: FAILED", archive)
+		for _, problem := range problems {
+			ui.Message("    %s", problem)
+		}
+	}
+
+	if invalidArchives > 0 {
+		ui.Fatal("%d of %d backups are invalid.", invalidArchives, len(archives))
+	}
+}
+
+// keyMatches checks if the supplied encrypted archive can be decrypted with the supplied key.
+func keyMatches(archivePath string, key *crypt.Key) bool {
+	matches, err := crypt.KeyMatches(archivePath, key)
+	return err != nil || matches // unreadable archives are reported by verifyTarArchive
+}
+
+// verifyTarArchive reads the supplied archive and compares
+// every file with the checksum recorded in the manifest.
+func verifyTarArchive(archivePath string, key *crypt.Key) []string {
+
+	problems := make([]string, 0)
+
+	var archiveManifest *manifest
+	checksums := make(map[string]string)
+
+	err := readTarArchive(archivePath, key, func(header *tar.Header, content io.Reader) error {
+
+		if header.Name == manifestEntryName {
+			manifestContent, err := ioutil.ReadAll(content)
+			if err != nil {
+				return err
+			}
+
+			archiveManifest, err = parseManifest(archivePath, manifestContent)
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			checksums[header.Name] = ""
+			return nil
+		}
+
+		hash := sha256.New()
+		if _, err := io.Copy(hash, content); err != nil {
+			return fmt.Errorf("Unable to read %q. %s", header.Name, err)
+		}
+
+		checksums[header.Name] = hex.EncodeToString(hash.Sum(nil))
+		return nil
+	})
+
+	if err != nil {
+		return append(problems, err.Error())
+	}
+
+	if archiveManifest == nil {
+		return append(problems, "The archive does not contain a manifest.")
+	}
+
+	for _, file := range archiveManifest.Files {
+
+		name := file.Path
+		if file.Type == fileTypeDirectory {
+			name += "/"
+		}
+
+		checksum, exists := checksums[name]
+		if !exists {
+			problems = append(problems, fmt.Sprintf("%s (module %q) is missing.", file.Path, file.Module))
+			continue
+		}
+
+		delete(checksums, name)
+
+		if checksum != file.Hash {
+			problems = append(problems, fmt.Sprintf("%s (module %q) has an invalid checksum.", file.Path, file.Module))
+		}
+	}
+
+	for name := range checksums {
+		problems = append(problems, fmt.Sprintf("%s is not listed in the manifest.", name))
+	}
+
+	return problems
+}
+
+// verifySnapshot checks that all objects of the supplied snapshot exist and match their hash.
+func verifySnapshot(snapshotPath string, store *objectStore) []string {
+
+	problems := make([]string, 0)
+
+	snapshotManifest, err := readManifest(snapshotPath)
+	if err != nil {
+		return append(problems, err.Error())
+	}
+
+	for _, file := range snapshotManifest.Files {
+		if file.Type != fileTypeRegular {
+			continue
+		}
+
+		objectPath, err := store.objectPath(file.Hash)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("The content of %s (module %q) cannot be found. %s", file.Path, file.Module, err))
+			continue
+		}
+
+		hash, err := getSha256Hash(objectPath)
+		if os.IsNotExist(err) {
+			problems = append(problems, fmt.Sprintf("The content of %s (module %q) is missing.", file.Path, file.Module))
+			continue
+		}
+
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Unable to read the content of %s (module %q). %s", file.Path, file.Module, err))
+			continue
+		}
+
+		if hash != file.Hash {
+			problems = append(problems, fmt.Sprintf("The content of %s (module %q) has an invalid checksum.", file.Path, file.Module))
+		}
+	}
+
+	return problems
+}
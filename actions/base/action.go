@@ -5,10 +5,17 @@
 package base
 
 import (
+	"flag"
 	"github.com/andreaskoch/dotman/modules"
 	"github.com/andreaskoch/dotman/ui"
-	"regexp"
-	"strings"
+)
+
+const (
+	// the flag which excludes the dependencies of the selected modules
+	NoDependenciesFlagName = "nodeps"
+
+	// the global flag which makes actions use modules whose module files contain errors
+	ForceFlagName = "force"
 )
 
 type ForEachModuleFunc func(module *modules.Module, executeADryRunOnly bool)
@@ -16,10 +23,14 @@ type ForEachModuleFunc func(module *modules.Module, executeADryRunOnly bool)
 type ModulesProviderFunc func() *modules.Collection
 
 type Action struct {
+	SkippedModules
+
 	name                     string
 	description              string
 	moduleCollectionProvider ModulesProviderFunc
 	forEachModule            ForEachModuleFunc
+
+	includeDependencies bool
 }
 
 func New(name, description string, moduleCollectionProvider ModulesProviderFunc, forEachModule ForEachModuleFunc) *Action {
@@ -31,6 +42,13 @@ func New(name, description string, moduleCollectionProvider ModulesProviderFunc,
 	}
 }
 
+// IncludeDependencies makes the action include the modules which the
+// filtered modules require (unless the -nodeps flag is supplied).
+func (action *Action) IncludeDependencies() *Action {
+	action.includeDependencies = true
+	return action
+}
+
 func (action *Action) Name() string {
 	return action.name
 }
@@ -49,32 +67,114 @@ func (action *Action) DryRun(arguments []string) {
 
 func (action *Action) execute(executeADryRunOnly bool, arguments []string) {
 
-	// extract the module filter from the arguments
-	moduleFilter := regexp.MustCompile(`.*`)
-	if len(arguments) > 0 && strings.TrimSpace(arguments[0]) != "" {
+	includeDependencies := action.includeDependencies
+	if includeDependencies {
+		options := flag.NewFlagSet(action.name, flag.ExitOnError)
+		noDependencies := options.Bool(NoDependenciesFlagName, false, "Don't include the modules which the selected modules require.")
+		options.Parse(arguments)
+
+		includeDependencies = !*noDependencies
+		arguments = options.Args()
+	}
 
-		// get the custom module filter from the command arguments
-		moduleFilterText := strings.TrimSpace(arguments[0])
+	// select the modules matching the arguments
+	moduleCollection := action.moduleCollectionProvider()
+	selectedModules := selectModules(moduleCollection, arguments)
+
+	if includeDependencies {
+		modulesWithDependencies, err := moduleCollection.WithDependencies(selectedModules)
+		if errors, isErrors := err.(modules.Errors); isErrors {
+			action.SkippedModules.Add(len(errors))
+		}
 
-		// try to compile the filter
-		customModuleFilter, err := regexp.Compile(moduleFilterText)
 		if err != nil {
-			ui.Fatal("%q is not a valid module filter. Error: %s", moduleFilterText, err.Error())
+			ui.Message("%s", err)
 		}
 
-		// assign the supplied custom filter
-		moduleFilter = customModuleFilter
+		selectedModules = modulesWithDependencies
 	}
 
-	modules := action.moduleCollectionProvider()
-	for _, module := range modules.Collection {
+	selectedModules, skippedModules := rejectInvalidModules(moduleCollection, selectedModules)
+	action.SkippedModules.Add(skippedModules)
 
-		// skip modules which don't match the filter
-		if !moduleFilter.MatchString(module.String()) {
+	for _, module := range selectedModules {
+		action.forEachModule(module, executeADryRunOnly)
+	}
+
+}
+
+// GetModuleSelector returns the module selector for the supplied arguments.
+func GetModuleSelector(arguments []string) *modules.Selector {
+	selector, err := modules.NewSelector(arguments)
+	if err != nil {
+		ui.Fatal("%s", err)
+	}
+
+	return selector
+}
+
+// SelectModules returns the modules of the supplied collection which are selected by the arguments
+// and the number of skipped modules. Modules whose module files contain errors are skipped
+// unless the collection is forced to use them.
+func SelectModules(moduleCollection *modules.Collection, arguments []string) ([]*modules.Module, int) {
+	return rejectInvalidModules(moduleCollection, selectModules(moduleCollection, arguments))
+}
+
+func selectModules(moduleCollection *modules.Collection, arguments []string) []*modules.Module {
+	selector := GetModuleSelector(arguments)
+
+	for _, name := range selector.UnknownNames(moduleCollection) {
+		ui.Message("There is no module named %q.", name)
+	}
+
+	return selector.Select(moduleCollection)
+}
+
+// rejectInvalidModules reports the errors and warnings in the module files of the supplied modules
+// and removes the modules with errors unless the collection is forced to use them. It returns the
+// remaining modules and the number of removed modules.
+func rejectInvalidModules(moduleCollection *modules.Collection, selectedModules []*modules.Module) ([]*modules.Module, int) {
+	validModules := make([]*modules.Module, 0, len(selectedModules))
+	skippedModules := 0
+	for _, module := range selectedModules {
+
+		for _, err := range module.Errors {
+			ui.Message("%s", err)
+		}
+
+		for _, warning := range module.Map.Warnings() {
+			ui.Message("Warning: %s", warning)
+		}
+
+		if len(module.Errors) > 0 && !moduleCollection.Force {
+			ui.Message("Skipping the module %q because its module file contains errors. Use the -%s flag to use it anyway.", module, ForceFlagName)
+			skippedModules++
 			continue
 		}
 
-		action.forEachModule(module, executeADryRunOnly)
+		validModules = append(validModules, module)
+	}
+
+	return validModules, skippedModules
+}
+
+// SkippedModules counts the selected modules which an action has skipped because of errors.
+// Actions embed it to report the exit code of dotman.
+type SkippedModules struct {
+	count int
+}
+
+// Add adds the supplied number of skipped modules.
+func (skippedModules *SkippedModules) Add(count int) {
+	skippedModules.count += count
+}
+
+// ExitCode returns the exit code of the executed action: 1 if any of the selected
+// modules has been skipped because of errors, 0 otherwise.
+func (skippedModules *SkippedModules) ExitCode() int {
+	if skippedModules.count > 0 {
+		return 1
 	}
 
+	return 0
 }
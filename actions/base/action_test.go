@@ -0,0 +1,67 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package base
+
+import (
+	"github.com/andreaskoch/dotman/modules"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestActionExitCode(t *testing.T) {
+
+	directory := t.TempDir()
+	moduleFiles := map[string]string{
+		"valid/dotman":   "rc  ~/.rc\n",
+		"invalid/dotman": "rc\n",
+	}
+
+	for path, content := range moduleFiles {
+		path = filepath.Join(directory, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		force            bool
+		expectedModules  []string
+		expectedExitCode int
+	}{
+		{false, []string{"valid"}, 1},
+		{true, []string{"invalid", "valid"}, 0},
+	}
+
+	for _, test := range tests {
+		moduleCollection, err := modules.Load(directory, modules.LoadOptions{MaxDepth: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		moduleCollection.Force = test.force
+
+		executedModules := make([]string, 0)
+		action := New("test", "", func() *modules.Collection { return moduleCollection }, func(module *modules.Module, executeADryRunOnly bool) {
+			executedModules = append(executedModules, module.String())
+		})
+
+		action.Execute(nil)
+
+		if !reflect.DeepEqual(executedModules, test.expectedModules) {
+			t.Errorf("The action (force: %v) executed the modules %q, expected %q", test.force, executedModules, test.expectedModules)
+		}
+
+		if exitCode := action.ExitCode(); exitCode != test.expectedExitCode {
+			t.Errorf("The action (force: %v) has the exit code %d, expected %d", test.force, exitCode, test.expectedExitCode)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/andreaskoch/dotman/mapping"
+	"github.com/andreaskoch/dotman/util/crypt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// GetSkipReason returns the reason why the supplied instruction must not
+// be executed or an empty string if it can be executed.
+func GetSkipReason(instruction *mapping.Instruction) string {
+
+	options := instruction.Options()
+
+	if options.Optional && !fs.PathExists(instruction.Source()) {
+		return fmt.Sprintf("%s does not exist", instruction.Source())
+	}
+
+	if instruction.IsReversed() {
+		if options.Link {
+			return "the target is a link to the module"
+		}
+
+		if options.Template {
+			return "the target is rendered from a template"
+		}
+
+		return ""
+	}
+
+	if _, err := os.Lstat(instruction.Target()); err == nil && options.Once {
+		return fmt.Sprintf("%s already exists", instruction.Target())
+	}
+
+	return ""
+}
+
+// Copy copies the source of the supplied instruction to its target.
+// Encrypted module files are decrypted when they are deployed and
+// encrypted when they are imported.
+func Copy(instruction *mapping.Instruction, keys *crypt.KeyProvider) error {
+
+	source := instruction.Source()
+	target := instruction.Target()
+	options := instruction.Options()
+
+	if options.Link && !instruction.IsReversed() {
+		return createLink(source, target)
+	}
+
+	var key *crypt.Key
+	if instruction.IsEncrypted() {
+		encryptionKey, err := keys.Key()
+		if err != nil {
+			return err
+		}
+
+		key = encryptionKey
+	}
+
+	copyFile := fs.CopyFile
+	switch {
+
+	// encrypt the files unless the module already contains the same content.
+	// Existing encrypted files which cannot be decrypted with the key are never overwritten.
+	case instruction.IsReversed() && instruction.IsEncrypted():
+		copyFile = func(source, target string) (bool, error) {
+			if crypt.IsEncryptedFile(target) {
+				filesAreEqual, err := crypt.FilesAreEqual(target, source, key)
+				if err != nil {
+					return false, err
+				}
+
+				if filesAreEqual {
+					return true, nil
+				}
+			}
+
+			return crypt.EncryptFile(source, target, key)
+		}
+
+	// import the plain files
+	case instruction.IsReversed():
+		copyFile = fs.CopyFile
+
+	// decrypt and render the module files
+	case options.Template:
+		copyFile = func(source, target string) (bool, error) {
+			content, err := RenderFile(instruction, source, key)
+			if err != nil {
+				return false, err
+			}
+
+			return writeFile(target, content)
+		}
+
+	// decrypt the module files
+	case instruction.IsEncrypted():
+		copyFile = func(source, target string) (bool, error) {
+			return crypt.DecryptFile(source, target, key)
+		}
+
+	}
+
+	_, err := fs.CopyWith(source, target, func(sourceFile, targetFile string) (bool, error) {
+
+		// skip excluded files
+		if relativePath, err := filepath.Rel(source, sourceFile); err == nil && relativePath != "." && options.IsExcluded(relativePath) {
+			return true, nil
+		}
+
+		if _, err := copyFile(sourceFile, targetFile); err != nil {
+			return false, err
+		}
+
+		// assign the permissions of deployed files
+		if options.HasMode && !instruction.IsReversed() {
+			if err := os.Chmod(targetFile, options.Mode); err != nil {
+				return false, err
+			}
+		}
+
+		return true, nil
+	})
+
+	return err
+}
+
+// RenderFile returns the content of the supplied module file rendered as a template
+// (see text/template) with the variables of the instruction. Encrypted files are
+// decrypted with the supplied key first.
+func RenderFile(instruction *mapping.Instruction, source string, key *crypt.Key) ([]byte, error) {
+
+	var content []byte
+	var err error
+	if instruction.IsEncrypted() {
+		content, err = crypt.ReadFile(source, key)
+	} else {
+		content, err = ioutil.ReadFile(source)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	functions := template.FuncMap{
+		"env": os.Getenv,
+	}
+
+	fileTemplate, err := template.New(filepath.Base(source)).Funcs(functions).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse the template %q. %s", source, err)
+	}
+
+	var buffer bytes.Buffer
+	if err := fileTemplate.Execute(&buffer, instruction.TemplateData()); err != nil {
+		return nil, fmt.Errorf("Unable to render the template %q. %s", source, err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// writeFile writes the supplied content to the target file. Existing
+// files keep their permissions, new files are only readable by the current user.
+func writeFile(target string, content []byte) (bool, error) {
+	if err := fs.WriteFileAtomically(target, bytes.NewReader(content), fs.GetFileMode(target, 0600)); err != nil {
+		return false, fmt.Errorf("Unable to write the target file %q. %s", target, err)
+	}
+
+	return true, nil
+}
+
+// createLink replaces the target with a symbolic link to the source.
+// Existing directories are never replaced.
+func createLink(source, target string) error {
+
+	if targetInfo, err := os.Lstat(target); err == nil {
+
+		if targetInfo.Mode()&os.ModeSymlink != 0 {
+			if link, err := os.Readlink(target); err == nil && link == source {
+				return nil
+			}
+		}
+
+		if targetInfo.IsDir() {
+			return fmt.Errorf("Cannot replace the directory %q with a link.", target)
+		}
+
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+
+	if !fs.CreateDirectory(filepath.Dir(target)) {
+		return fmt.Errorf("Unable to create the directory for %q.", target)
+	}
+
+	return os.Symlink(source, target)
+}
@@ -0,0 +1,62 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package base
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileModes(t *testing.T) {
+
+	directory := t.TempDir()
+
+	tests := []struct {
+		name         string
+		existingMode os.FileMode // 0 if the target doesn't exist
+		expectedMode os.FileMode
+	}{
+		{"new", 0, 0600},
+		{"private", 0600, 0600},
+		{"readable", 0644, 0644},
+		{"executable", 0755, 0755},
+	}
+
+	for _, test := range tests {
+		target := filepath.Join(directory, test.name, "rendered")
+		if test.existingMode != 0 {
+			os.MkdirAll(filepath.Dir(target), 0700)
+			if err := ioutil.WriteFile(target, []byte("old"), test.existingMode); err != nil {
+				t.Fatal(err)
+			}
+
+			// the umask doesn't apply to chmod
+			if err := os.Chmod(target, test.existingMode); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := writeFile(target, []byte("rendered")); err != nil {
+			t.Errorf("writeFile(%q) failed: %s", target, err)
+			continue
+		}
+
+		fileInfo, err := os.Stat(target)
+		if err != nil {
+			t.Errorf("writeFile(%q) created no file: %s", target, err)
+			continue
+		}
+
+		if mode := fileInfo.Mode().Perm(); mode != test.expectedMode {
+			t.Errorf("writeFile(%q) created a file with mode %04o, expected %04o", target, mode, test.expectedMode)
+		}
+
+		if content, _ := ioutil.ReadFile(target); string(content) != "rendered" {
+			t.Errorf("writeFile(%q) wrote %q, expected \"rendered\"", target, content)
+		}
+	}
+}
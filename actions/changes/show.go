@@ -5,11 +5,16 @@
 package changes
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/andreaskoch/dotman/actions/base"
 	"github.com/andreaskoch/dotman/modules"
 	"github.com/andreaskoch/dotman/ui"
+	"github.com/andreaskoch/dotman/util/crypt"
 	"github.com/andreaskoch/dotman/util/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 )
 
 const (
@@ -22,11 +27,12 @@ type Importer struct {
 }
 
 func New(moduleCollectionProvider base.ModulesProviderFunc) *Importer {
+	keys := crypt.NewKeyProvider("", false)
 	return &Importer{
 		base.New(ActionName, ActionDescription, moduleCollectionProvider, func(module *modules.Module, executeADryRunOnly bool) {
 
 			moduleTitleHasBeenPrinted := false
-			for change := range showChanges(module) {
+			for change := range showChanges(module, keys) {
 
 				// print module title
 				if !moduleTitleHasBeenPrinted {
@@ -42,15 +48,71 @@ func New(moduleCollectionProvider base.ModulesProviderFunc) *Importer {
 	}
 }
 
-func showChanges(module *modules.Module) (changes chan string) {
+func showChanges(module *modules.Module, keys *crypt.KeyProvider) (changes chan string) {
 
 	changes = make(chan string, 10)
 
+	instructions := module.Map.GetInstructions()
+
+	// encrypted module files are compared by their plain text
+	var key *crypt.Key
+	for _, instruction := range instructions {
+		if instruction.IsEncrypted() {
+			encryptionKey, err := keys.Key()
+			if err != nil {
+				ui.Fatal("%s", err)
+			}
+
+			key = encryptionKey
+			break
+		}
+	}
+
 	go func() {
-		for _, instruction := range module.Map.GetInstructions() {
+		for _, instruction := range instructions {
 
 			source := instruction.Source()
 			target := instruction.Target()
+			options := instruction.Options()
+
+			// ignore optional entries without a source and entries which are only deployed once
+			if base.GetSkipReason(instruction) != "" {
+				continue
+			}
+
+			// links must point to the module
+			if options.Link {
+				if link, err := os.Readlink(target); err != nil || link != source {
+					changes <- fmt.Sprintf("%s is not a link to %s.", target, source)
+				}
+
+				continue
+			}
+
+			filesAreEqual := fs.FilesAreEqual
+			switch {
+
+			case options.Template:
+				filesAreEqual = func(source, target string) (bool, error) {
+					renderedContent, err := base.RenderFile(instruction, source, key)
+					if err != nil {
+						return false, err
+					}
+
+					targetContent, err := ioutil.ReadFile(target)
+					if err != nil {
+						return false, err
+					}
+
+					return bytes.Equal(renderedContent, targetContent), nil
+				}
+
+			case instruction.IsEncrypted():
+				filesAreEqual = func(source, target string) (bool, error) {
+					return crypt.FilesAreEqual(source, target, key)
+				}
+
+			}
 
 			// check if the target exists
 			if fs.PathExists(source) && !fs.PathExists(target) {
@@ -73,13 +135,19 @@ func showChanges(module *modules.Module) (changes chan string) {
 			// compare directories
 			if fs.IsDirectory(source) {
 
-				directoriesAreEqual, filesThatAreDifferent, err := fs.DirectoriesAreEqual(source, target)
+				directoriesAreEqual, filesThatAreDifferent, err := fs.DirectoriesAreEqualWith(source, target, filesAreEqual)
 				if err != nil {
 					ui.Fatal("Error while comparing the directories %q and %q. Error: %s", source, target, err)
 				}
 
 				if !directoriesAreEqual {
 					for _, changedFile := range filesThatAreDifferent {
+
+						// ignore excluded files
+						if relativePath, err := filepath.Rel(target, changedFile); err == nil && options.IsExcluded(relativePath) {
+							continue
+						}
+
 						changes <- fmt.Sprintf("%s", changedFile)
 					}
 				}
@@ -88,9 +156,9 @@ func showChanges(module *modules.Module) (changes chan string) {
 			}
 
 			// compare files
-			areEqual, err := fs.FilesAreEqual(source, target)
+			areEqual, err := filesAreEqual(source, target)
 			if err != nil {
-				ui.Fatal("Error while comparing the files %q and %q. Error: %s", err)
+				ui.Fatal("Error while comparing the files %q and %q. Error: %s", source, target, err)
 			}
 
 			if !areEqual {
@@ -17,6 +17,8 @@ const (
 )
 
 type Commit struct {
+	base.SkippedModules
+
 	baseDirectory            string
 	moduleCollectionProvider base.ModulesProviderFunc
 }
@@ -51,16 +53,16 @@ func (commit *Commit) execute(executeADryRunOnly bool, arguments []string) {
 		return
 	}
 
-	// extract the repository url from the arguments
-	commitMessage := ""
-	if len(arguments) > 0 {
-		commitMessage = strings.TrimSpace(strings.Join(arguments[0:], ""))
-		commitMessage = strings.Trim(commitMessage, `"'`)
-	}
+	// extract the commit message from the arguments
+	commitMessage := strings.TrimSpace(arguments[0])
+	commitMessage = strings.Trim(commitMessage, `"'`)
 
-	// commit all submodules
+	// commit all selected submodules
 	modules := commit.moduleCollectionProvider()
-	for _, module := range modules.Collection {
+	selectedModules, skippedModules := base.SelectModules(modules, arguments[1:])
+	commit.SkippedModules.Add(skippedModules)
+
+	for _, module := range selectedModules {
 
 		ui.Message("Commiting changes in sub-module %q.", module)
 
@@ -0,0 +1,91 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package convert
+
+import (
+	"flag"
+	"github.com/andreaskoch/dotman/actions/base"
+	"github.com/andreaskoch/dotman/modules"
+	"github.com/andreaskoch/dotman/ui"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	ActionName        = "convert"
+	ActionDescription = "Convert module files to another format (json or text)."
+)
+
+type Convert struct {
+	base.SkippedModules
+
+	moduleCollectionProvider base.ModulesProviderFunc
+}
+
+func New(moduleCollectionProvider base.ModulesProviderFunc) *Convert {
+	return &Convert{
+		moduleCollectionProvider: moduleCollectionProvider,
+	}
+}
+
+func (convert *Convert) Name() string {
+	return ActionName
+}
+
+func (convert *Convert) Description() string {
+	return ActionDescription
+}
+
+func (convert *Convert) Execute(arguments []string) {
+	convert.execute(false, arguments)
+}
+
+func (convert *Convert) DryRun(arguments []string) {
+	convert.execute(true, arguments)
+}
+
+func (convert *Convert) execute(executeADryRunOnly bool, arguments []string) {
+
+	options := flag.NewFlagSet(ActionName, flag.ExitOnError)
+	format := options.String("format", modules.JSONFormat, "The format of the converted module files (json or text).")
+	options.Parse(arguments)
+
+	if *format != modules.JSONFormat && *format != modules.TextFormat {
+		ui.Fatal("%q is not a known format. Please use %q or %q.", *format, modules.JSONFormat, modules.TextFormat)
+	}
+
+	moduleCollection := convert.moduleCollectionProvider()
+	selectedModules, skippedModules := base.SelectModules(moduleCollection, options.Args())
+	convert.SkippedModules.Add(skippedModules)
+
+	for _, module := range selectedModules {
+
+		if module.Format() == *format {
+			ui.Message("The module %q already uses the %s format.", module, *format)
+			continue
+		}
+
+		path, content, err := module.Convert(*format)
+		if err != nil {
+			ui.Message("Unable to convert the module %q. %s", module, err)
+			continue
+		}
+
+		ui.Message("Convert %s → %s", module.ModuleFile(), path)
+		if executeADryRunOnly {
+			continue
+		}
+
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			ui.Message("%s", err)
+			continue
+		}
+
+		// a module can only have one module file
+		if err := os.Remove(module.ModuleFile()); err != nil {
+			ui.Message("%s", err)
+		}
+	}
+}
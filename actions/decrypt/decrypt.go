@@ -0,0 +1,114 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package decrypt
+
+import (
+	"flag"
+	"github.com/andreaskoch/dotman/actions/encrypt"
+	"github.com/andreaskoch/dotman/ui"
+	"github.com/andreaskoch/dotman/util/crypt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"path/filepath"
+)
+
+const (
+	ActionName        = "decrypt"
+	ActionDescription = "Decrypt encrypted files in a module."
+)
+
+type Decrypt struct {
+	baseDirectory string
+}
+
+func New(baseDirectory string) *Decrypt {
+	return &Decrypt{
+		baseDirectory: baseDirectory,
+	}
+}
+
+func (decrypt *Decrypt) Name() string {
+	return ActionName
+}
+
+func (decrypt *Decrypt) Description() string {
+	return ActionDescription
+}
+
+func (decrypt *Decrypt) Execute(arguments []string) {
+	decrypt.execute(false, arguments)
+}
+
+func (decrypt *Decrypt) DryRun(arguments []string) {
+	decrypt.execute(true, arguments)
+}
+
+func (decrypt *Decrypt) execute(executeADryRunOnly bool, arguments []string) {
+
+	options := flag.NewFlagSet(ActionName, flag.ExitOnError)
+	keyFile := options.String("keyfile", "", "The key file used to decrypt the files.")
+	force := options.Bool("force", false, "Decrypt files in a git working tree although the next commit would contain them unencrypted.")
+	options.Parse(arguments)
+
+	if options.NArg() == 0 {
+		ui.Message("Please specify the files you want to decrypt.")
+		return
+	}
+
+	keys := crypt.NewKeyProvider(*keyFile, false)
+
+	decryptedFiles := 0
+	for _, path := range encrypt.GetFiles(decrypt.baseDirectory, options.Args()) {
+
+		if !crypt.IsEncryptedFile(path) {
+			ui.Message("%s is not encrypted.", path)
+			continue
+		}
+
+		// the plain text would be published with the next commit
+		if repository, isInRepository := getGitWorkingTree(path); isInRepository && !*force {
+			ui.Message("%s is part of the git repository %q, so the next commit would contain it unencrypted. Use the -force flag to decrypt it anyway.", path, repository)
+			continue
+		}
+
+		ui.Message("Decrypting %s", path)
+		if executeADryRunOnly {
+			continue
+		}
+
+		key, err := keys.Key()
+		if err != nil {
+			ui.Fatal("%s", err)
+		}
+
+		if _, err := crypt.DecryptFile(path, path, key); err != nil {
+			ui.Message("%s", err)
+			continue
+		}
+
+		decryptedFiles++
+	}
+
+	if decryptedFiles > 0 {
+		ui.Message("Don't forget to encrypt the files again before you commit them.")
+	}
+}
+
+// getGitWorkingTree returns the directory of the git working tree which contains the supplied path.
+func getGitWorkingTree(path string) (string, bool) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+
+	for directory := filepath.Dir(absolutePath); ; directory = filepath.Dir(directory) {
+		if fs.PathExists(filepath.Join(directory, ".git")) {
+			return directory, true
+		}
+
+		if directory == filepath.Dir(directory) {
+			return "", false
+		}
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/andreaskoch/dotman/actions/base"
 	"github.com/andreaskoch/dotman/modules"
 	"github.com/andreaskoch/dotman/ui"
-	"github.com/andreaskoch/dotman/util/fs"
+	"github.com/andreaskoch/dotman/util/crypt"
 )
 
 const (
@@ -21,24 +21,34 @@ type Deploy struct {
 }
 
 func New(moduleCollectionProvider base.ModulesProviderFunc) *Deploy {
+	keys := crypt.NewKeyProvider("", false)
 	return &Deploy{
 		base.New(ActionName, ActionDescription, moduleCollectionProvider, func(module *modules.Module, executeADryRunOnly bool) {
 			ui.Message("Deploying %q", module)
-			deployModule(module, executeADryRunOnly)
-		}),
+			deployModule(module, keys, executeADryRunOnly)
+		}).IncludeDependencies(),
 	}
 }
 
-func deployModule(module *modules.Module, executeADryRunOnly bool) {
+func deployModule(module *modules.Module, keys *crypt.KeyProvider, executeADryRunOnly bool) {
 
 	for _, instruction := range module.Map.GetInstructions() {
 
 		source := instruction.Source()
 		target := instruction.Target()
 
-		ui.Message("Copy %s → %s", source, target)
+		if reason := base.GetSkipReason(instruction); reason != "" {
+			ui.Message("Skip %s → %s (%s)", source, target, reason)
+			continue
+		}
+
+		if instruction.Options().Link {
+			ui.Message("Link %s → %s", source, target)
+		} else {
+			ui.Message("Copy %s → %s", source, target)
+		}
 		if !executeADryRunOnly {
-			if _, err := fs.Copy(source, target); err != nil {
+			if err := base.Copy(instruction, keys); err != nil {
 				ui.Message("%s", err)
 			}
 		}
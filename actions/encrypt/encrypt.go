@@ -0,0 +1,107 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package encrypt
+
+import (
+	"flag"
+	"github.com/andreaskoch/dotman/ui"
+	"github.com/andreaskoch/dotman/util/crypt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"path/filepath"
+)
+
+const (
+	ActionName        = "encrypt"
+	ActionDescription = "Encrypt files in a module."
+)
+
+type Encrypt struct {
+	baseDirectory string
+}
+
+func New(baseDirectory string) *Encrypt {
+	return &Encrypt{
+		baseDirectory: baseDirectory,
+	}
+}
+
+func (encrypt *Encrypt) Name() string {
+	return ActionName
+}
+
+func (encrypt *Encrypt) Description() string {
+	return ActionDescription
+}
+
+func (encrypt *Encrypt) Execute(arguments []string) {
+	encrypt.execute(false, arguments)
+}
+
+func (encrypt *Encrypt) DryRun(arguments []string) {
+	encrypt.execute(true, arguments)
+}
+
+func (encrypt *Encrypt) execute(executeADryRunOnly bool, arguments []string) {
+
+	options := flag.NewFlagSet(ActionName, flag.ExitOnError)
+	keyFile := options.String("keyfile", "", "The key file used to encrypt the files.")
+	options.Parse(arguments)
+
+	if options.NArg() == 0 {
+		ui.Message("Please specify the files you want to encrypt.")
+		return
+	}
+
+	keys := crypt.NewKeyProvider(*keyFile, true)
+
+	for _, path := range GetFiles(encrypt.baseDirectory, options.Args()) {
+
+		if crypt.IsEncryptedFile(path) {
+			ui.Message("%s is already encrypted.", path)
+			continue
+		}
+
+		ui.Message("Encrypting %s", path)
+		if executeADryRunOnly {
+			continue
+		}
+
+		key, err := keys.Key()
+		if err != nil {
+			ui.Fatal("%s", err)
+		}
+
+		if _, err := crypt.EncryptFile(path, path, key); err != nil {
+			ui.Message("%s", err)
+		}
+	}
+}
+
+// GetFiles returns all files matching the supplied paths (relative to the base directory).
+// Directories are expanded to all files they contain.
+func GetFiles(baseDirectory string, paths []string) []string {
+
+	files := make([]string, 0)
+	for _, path := range paths {
+
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDirectory, path)
+		}
+
+		if fs.IsDirectory(path) {
+			files = append(files, fs.GetAllFilesRecursively(path)...)
+			continue
+		}
+
+		if !fs.FileExists(path) {
+			ui.Message("%s does not exist.", path)
+			continue
+		}
+
+		files = append(files, path)
+	}
+
+	return files
+}
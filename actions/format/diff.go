@@ -0,0 +1,99 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+// getDiff returns the changed lines between the supplied contents of a file in the
+// unified diff format without context lines (like "diff -U0").
+func getDiff(path, oldContent, newContent string) string {
+
+	oldLines, newLines := getDiffLines(oldContent), getDiffLines(newContent)
+
+	// the length of the longest common subsequence of the lines from the indices onwards
+	lengths := make([][]int, len(oldLines)+1)
+	for oldIndex := range lengths {
+		lengths[oldIndex] = make([]int, len(newLines)+1)
+	}
+
+	for oldIndex := len(oldLines) - 1; oldIndex >= 0; oldIndex-- {
+		for newIndex := len(newLines) - 1; newIndex >= 0; newIndex-- {
+			switch {
+
+			case oldLines[oldIndex] == newLines[newIndex]:
+				lengths[oldIndex][newIndex] = lengths[oldIndex+1][newIndex+1] + 1
+
+			case lengths[oldIndex+1][newIndex] >= lengths[oldIndex][newIndex+1]:
+				lengths[oldIndex][newIndex] = lengths[oldIndex+1][newIndex]
+
+			default:
+				lengths[oldIndex][newIndex] = lengths[oldIndex][newIndex+1]
+
+			}
+		}
+	}
+
+	diff := fmt.Sprintf("--- %s\n+++ %s (formatted)\n", path, path)
+
+	// collect the removed and added lines between two common lines as a hunk
+	oldIndex, newIndex := 0, 0
+	for oldIndex < len(oldLines) || newIndex < len(newLines) {
+		if oldIndex < len(oldLines) && newIndex < len(newLines) && oldLines[oldIndex] == newLines[newIndex] {
+			oldIndex++
+			newIndex++
+			continue
+		}
+
+		oldStart, newStart := oldIndex, newIndex
+		for oldIndex < len(oldLines) || newIndex < len(newLines) {
+			if oldIndex < len(oldLines) && newIndex < len(newLines) && oldLines[oldIndex] == newLines[newIndex] {
+				break
+			}
+
+			if newIndex == len(newLines) || (oldIndex < len(oldLines) && lengths[oldIndex+1][newIndex] >= lengths[oldIndex][newIndex+1]) {
+				oldIndex++
+			} else {
+				newIndex++
+			}
+		}
+
+		diff += fmt.Sprintf("@@ -%s +%s @@\n", getHunkRange(oldStart, oldIndex-oldStart), getHunkRange(newStart, newIndex-newStart))
+		for _, line := range oldLines[oldStart:oldIndex] {
+			diff += "-" + line + "\n"
+		}
+
+		for _, line := range newLines[newStart:newIndex] {
+			diff += "+" + line + "\n"
+		}
+	}
+
+	return diff
+}
+
+// getDiffLines returns the lines of the supplied content.
+func getDiffLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// getHunkRange returns the line range of a hunk which starts after the supplied number of
+// lines (a hunk without lines refers to the line in front of it).
+func getHunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,33 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package format
+
+import (
+	"testing"
+)
+
+func TestGetDiff(t *testing.T) {
+
+	tests := []struct {
+		oldContent string
+		newContent string
+		expected   string // without the header
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"a\nb\nc\n", "a\nB\nc\n", "@@ -2 +2 @@\n-b\n+B\n"},
+		{"a  ~/.a\nlonger\t~/.longer\n", "a       ~/.a\nlonger  ~/.longer\n", "@@ -1,2 +1,2 @@\n-a  ~/.a\n-longer\t~/.longer\n+a       ~/.a\n+longer  ~/.longer\n"},
+		{"a\nb\nc\n", "a\nc\n", "@@ -2 +1,0 @@\n-b\n"},
+		{"a\nc\n", "a\nb\nc\n", "@@ -1,0 +2 @@\n+b\n"},
+		{"a\nx\nc\ny\n", "a\nX\nc\nY\n", "@@ -2 +2 @@\n-x\n+X\n@@ -4 +4 @@\n-y\n+Y\n"},
+		{"", "a\n", "@@ -0,0 +1 @@\n+a\n"},
+	}
+
+	for _, test := range tests {
+		expected := "--- dotman\n+++ dotman (formatted)\n" + test.expected
+		if diff := getDiff("dotman", test.oldContent, test.newContent); diff != expected {
+			t.Errorf("getDiff(%q, %q) = %q, expected %q", test.oldContent, test.newContent, diff, expected)
+		}
+	}
+}
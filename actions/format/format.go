@@ -0,0 +1,123 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package format
+
+import (
+	"bytes"
+	"flag"
+	"github.com/andreaskoch/dotman/actions/base"
+	"github.com/andreaskoch/dotman/mapping"
+	"github.com/andreaskoch/dotman/modules"
+	"github.com/andreaskoch/dotman/ui"
+	"github.com/andreaskoch/dotman/util/fs"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	ActionName        = "fmt"
+	ActionDescription = "Format the module files."
+)
+
+type Format struct {
+	base.SkippedModules
+
+	moduleCollectionProvider base.ModulesProviderFunc
+}
+
+func New(moduleCollectionProvider base.ModulesProviderFunc) *Format {
+	return &Format{
+		moduleCollectionProvider: moduleCollectionProvider,
+	}
+}
+
+func (format *Format) Name() string {
+	return ActionName
+}
+
+func (format *Format) Description() string {
+	return ActionDescription
+}
+
+func (format *Format) Execute(arguments []string) {
+	format.execute(false, arguments)
+}
+
+func (format *Format) DryRun(arguments []string) {
+	format.execute(true, arguments)
+}
+
+func (format *Format) execute(executeADryRunOnly bool, arguments []string) {
+
+	options := flag.NewFlagSet(ActionName, flag.ExitOnError)
+	check := options.Bool("check", false, "Don't change the module files but exit with status 1 if any of them is not formatted.")
+	showDiff := options.Bool("diff", false, "Don't change the module files but print the changes which formatting would make.")
+	options.Parse(arguments)
+
+	moduleCollection := format.moduleCollectionProvider()
+	selectedModules, skippedModules := base.SelectModules(moduleCollection, options.Args())
+	format.SkippedModules.Add(skippedModules)
+
+	// modules which are skipped because of errors cannot be checked
+	unformattedFiles := skippedModules
+	for _, module := range selectedModules {
+
+		content, err := getFormattedContent(module)
+		if err != nil {
+			ui.Message("Unable to format the module %q. %s", module, err)
+			unformattedFiles++
+			continue
+		}
+
+		currentContent, err := ioutil.ReadFile(module.ModuleFile())
+		if err != nil {
+			ui.Fatal("%s", err)
+		}
+
+		if string(currentContent) == string(content) {
+			continue
+		}
+
+		unformattedFiles++
+		switch {
+
+		case *showDiff:
+			ui.Message("%s", strings.TrimSuffix(getDiff(module.ModuleFile(), string(currentContent), string(content)), "\n"))
+			continue
+
+		case *check:
+			ui.Message("%s is not formatted.", module.ModuleFile())
+			continue
+
+		}
+
+		ui.Message("Formatting %s", module.ModuleFile())
+		if executeADryRunOnly {
+			continue
+		}
+
+		// keep the permissions of the module file
+		mode := fs.GetFileMode(module.ModuleFile(), 0644)
+		if err := fs.WriteFileAtomically(module.ModuleFile(), bytes.NewReader(content), mode); err != nil {
+			ui.Message("%s", err)
+		}
+	}
+
+	if *check && unformattedFiles > 0 {
+		os.Exit(1)
+	}
+}
+
+// getFormattedContent returns the canonical content of the module file of the supplied module.
+func getFormattedContent(module *modules.Module) ([]byte, error) {
+	if module.Format() == modules.JSONFormat {
+		_, content, err := module.Convert(modules.JSONFormat)
+		return content, err
+	}
+
+	content, err := mapping.FormatFile(module.ModuleFile())
+	return []byte(content), err
+}
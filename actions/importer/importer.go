@@ -8,7 +8,7 @@ import (
 	"github.com/andreaskoch/dotman/actions/base"
 	"github.com/andreaskoch/dotman/modules"
 	"github.com/andreaskoch/dotman/ui"
-	"github.com/andreaskoch/dotman/util/fs"
+	"github.com/andreaskoch/dotman/util/crypt"
 )
 
 const (
@@ -21,24 +21,30 @@ type Importer struct {
 }
 
 func New(moduleCollectionProvider base.ModulesProviderFunc) *Importer {
+	keys := crypt.NewKeyProvider("", true)
 	return &Importer{
 		base.New(ActionName, ActionDescription, moduleCollectionProvider, func(module *modules.Module, executeADryRunOnly bool) {
 			ui.Message("\nImporting %q:", module)
-			importModule(module, executeADryRunOnly)
+			importModule(module, keys, executeADryRunOnly)
 		}),
 	}
 }
 
-func importModule(module *modules.Module, executeADryRunOnly bool) {
+func importModule(module *modules.Module, keys *crypt.KeyProvider, executeADryRunOnly bool) {
 
 	for _, instruction := range module.Map.Reverse().GetInstructions() {
 
 		source := instruction.Source()
 		target := instruction.Target()
 
+		if reason := base.GetSkipReason(instruction); reason != "" {
+			ui.Message("Skip %s → %s (%s)", source, target, reason)
+			continue
+		}
+
 		ui.Message("Copy %s → %s", source, target)
 		if !executeADryRunOnly {
-			if _, err := fs.Copy(source, target); err != nil {
+			if err := base.Copy(instruction, keys); err != nil {
 				ui.Message("%s", err)
 			}
 		}
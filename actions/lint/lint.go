@@ -0,0 +1,264 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lint
+
+import (
+	"fmt"
+	"github.com/andreaskoch/dotman/actions/base"
+	"github.com/andreaskoch/dotman/mapping"
+	"github.com/andreaskoch/dotman/modules"
+	"github.com/andreaskoch/dotman/ui"
+	"github.com/andreaskoch/dotman/util/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ActionName        = "lint"
+	ActionDescription = "Check the modules for errors."
+)
+
+const (
+	errorSeverity   = "error"
+	warningSeverity = "warning"
+)
+
+var (
+	// the directories below the home directory which contain secrets
+	sensitiveDirectories = []string{".ssh", ".gnupg"}
+)
+
+// ModulesLoaderFunc returns all modules (even if some of them could not be loaded or
+// don't support the current operating system) and the problems which occured while
+// loading them (as modules.Errors).
+type ModulesLoaderFunc func() (*modules.Collection, error)
+
+type Lint struct {
+	moduleCollectionLoader ModulesLoaderFunc
+}
+
+func New(moduleCollectionLoader ModulesLoaderFunc) *Lint {
+	return &Lint{
+		moduleCollectionLoader: moduleCollectionLoader,
+	}
+}
+
+func (lint *Lint) Name() string {
+	return ActionName
+}
+
+func (lint *Lint) Description() string {
+	return ActionDescription
+}
+
+func (lint *Lint) Execute(arguments []string) {
+	lint.execute(arguments)
+}
+
+// DryRun does the same as Execute because linting doesn't change anything.
+func (lint *Lint) DryRun(arguments []string) {
+	lint.execute(arguments)
+}
+
+func (lint *Lint) execute(arguments []string) {
+
+	report := newReport()
+
+	moduleCollection, err := lint.moduleCollectionLoader()
+	if moduleCollection == nil {
+		ui.Fatal("Unable to load modules. %s", err)
+	}
+
+	if loadErrors, isLoadErrors := err.(modules.Errors); isLoadErrors {
+		for _, loadError := range loadErrors {
+			report.add(errorSeverity, "", "%s", loadError)
+		}
+	} else if err != nil {
+		report.add(errorSeverity, "", "%s", err)
+	}
+
+	selector := base.GetModuleSelector(arguments)
+	for _, name := range selector.UnknownNames(moduleCollection) {
+		report.add(errorSeverity, "", "There is no module named %q.", name)
+	}
+
+	homeDirectory, err := fs.GetUserHomeDirectory()
+	if err != nil {
+		ui.Fatal("Unable to determine the home directory. %s", err)
+	}
+
+	targets := make([]*target, 0)
+	for _, module := range selector.Select(moduleCollection) {
+
+		// invalid lines, patterns and undefined variables
+		for _, parseError := range module.Errors {
+			report.add(errorSeverity, "", "%s", parseError)
+		}
+
+		// ambiguous targets and patterns which match nothing
+		for _, warning := range module.Map.Warnings() {
+			report.add(warningSeverity, "", "%s", warning)
+		}
+
+		// patterns without a source directory
+		for _, instruction := range module.Map.GetUnmatchedEntries() {
+			if !fs.PathExists(instruction.Source()) && !instruction.Options().Optional {
+				report.add(errorSeverity, instruction.Position(), "The source %q does not exist.", instruction.Source())
+			}
+		}
+
+		for _, instruction := range module.Map.GetInstructions() {
+
+			if !fs.PathExists(instruction.Source()) && !instruction.Options().Optional {
+				report.add(errorSeverity, instruction.Position(), "The source %q does not exist.", instruction.Source())
+			}
+
+			if !fs.IsSameOrInside(instruction.Target(), homeDirectory) {
+				report.add(warningSeverity, instruction.Position(), "The target %q is outside of your home directory.", instruction.Target())
+			}
+
+			checkPermissions(report, instruction, homeDirectory)
+
+			targets = append(targets, &target{module, instruction})
+		}
+	}
+
+	checkTargets(report, targets)
+
+	// print the report
+	for _, problem := range report.problems {
+		ui.Message("%s", problem)
+	}
+
+	if len(report.problems) == 0 {
+		ui.Message("No problems found.")
+		return
+	}
+
+	ui.Message("%d error(s), %d warning(s)", report.count(errorSeverity), report.count(warningSeverity))
+	if report.count(errorSeverity) > 0 {
+		os.Exit(1)
+	}
+}
+
+// a target path and the module and instruction it belongs to
+type target struct {
+	module      *modules.Module
+	instruction *mapping.Instruction
+}
+
+func (target *target) String() string {
+	return fmt.Sprintf("%s (module %q)", target.instruction.Position(), target.module)
+}
+
+// checkTargets reports targets which are used by several entries and targets
+// which are located inside the target of another entry.
+func checkTargets(report *report, targets []*target) {
+	for index, first := range targets {
+		for _, second := range targets[index+1:] {
+
+			// modules for different operating systems are never deployed together
+			if !haveCommonOperatingSystem(first.module.Metadata, second.module.Metadata) {
+				continue
+			}
+
+			firstPath, secondPath := first.instruction.Target(), second.instruction.Target()
+			switch {
+
+			case firstPath == secondPath:
+				report.add(errorSeverity, second.instruction.Position(), "The target %q is already used by %s.", secondPath, first)
+
+			case fs.IsSameOrInside(secondPath, firstPath):
+				report.add(warningSeverity, second.instruction.Position(), "The target %q is inside the target %q of %s.", secondPath, firstPath, first)
+
+			case fs.IsSameOrInside(firstPath, secondPath):
+				report.add(warningSeverity, first.instruction.Position(), "The target %q is inside the target %q of %s.", firstPath, secondPath, second)
+
+			}
+		}
+	}
+}
+
+// haveCommonOperatingSystem checks if there is an operating system which both modules support.
+func haveCommonOperatingSystem(first, second *modules.Metadata) bool {
+	if len(first.OperatingSystems) == 0 || len(second.OperatingSystems) == 0 {
+		return true
+	}
+
+	for _, firstOperatingSystem := range first.OperatingSystems {
+		for _, secondOperatingSystem := range second.OperatingSystems {
+			if strings.EqualFold(firstOperatingSystem, secondOperatingSystem) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkPermissions reports modes which let other users change the deployed files
+// and secrets which other users can read.
+func checkPermissions(report *report, instruction *mapping.Instruction, homeDirectory string) {
+	mode := instruction.Options().Mode
+
+	if mode&0022 != 0 {
+		report.add(warningSeverity, instruction.Position(), "The mode %04o lets other users change %q.", mode, instruction.Target())
+	}
+
+	if mode&0077 != 0 && (instruction.IsEncrypted() || isSensitive(instruction.Target(), homeDirectory)) {
+		report.add(warningSeverity, instruction.Position(), "%q contains secrets but the mode %04o lets other users read it (use mode=0600).", instruction.Target(), mode)
+	}
+}
+
+func isSensitive(path, homeDirectory string) bool {
+	for _, directory := range sensitiveDirectories {
+		if fs.IsSameOrInside(path, filepath.Join(homeDirectory, directory)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// a report contains the problems found in the modules
+type report struct {
+	problems []*problem
+}
+
+type problem struct {
+	severity string
+	position string
+	message  string
+}
+
+func (problem *problem) String() string {
+	if problem.position == "" {
+		return fmt.Sprintf("%s: %s", problem.severity, problem.message)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", problem.severity, problem.position, problem.message)
+}
+
+func newReport() *report {
+	return &report{
+		problems: make([]*problem, 0),
+	}
+}
+
+func (report *report) add(severity, position, format string, args ...interface{}) {
+	report.problems = append(report.problems, &problem{severity, position, fmt.Sprintf(format, args...)})
+}
+
+func (report *report) count(severity string) int {
+	count := 0
+	for _, problem := range report.problems {
+		if problem.severity == severity {
+			count++
+		}
+	}
+
+	return count
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/andreaskoch/dotman/actions/base"
 	"github.com/andreaskoch/dotman/modules"
 	"github.com/andreaskoch/dotman/ui"
+	"strings"
 )
 
 const (
@@ -22,7 +23,22 @@ type List struct {
 func New(moduleCollectionProvider base.ModulesProviderFunc) *List {
 	return &List{
 		base.New(ActionName, ActionDescription, moduleCollectionProvider, func(module *modules.Module, executeADryRunOnly bool) {
-			ui.Message("%s", module)
+			ui.Message("%s", getModuleSummary(module))
 		}),
 	}
 }
+
+// getModuleSummary returns the name of the supplied module followed by its description and tags.
+func getModuleSummary(module *modules.Module) string {
+	summary := module.String()
+
+	if module.Metadata.Description != "" {
+		summary += " - " + module.Metadata.Description
+	}
+
+	if len(module.Metadata.Tags) > 0 {
+		summary += " [" + strings.Join(module.Metadata.Tags, ", ") + "]"
+	}
+
+	return summary
+}
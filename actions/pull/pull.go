@@ -16,6 +16,8 @@ const (
 )
 
 type Pull struct {
+	base.SkippedModules
+
 	baseDirectory            string
 	moduleCollectionProvider base.ModulesProviderFunc
 }
@@ -55,9 +57,12 @@ func (pull *Pull) execute(executeADryRunOnly bool, arguments []string) {
 		ui.Fatal("Error while pull changes for the main repository:\n%s", err)
 	}
 
-	// pull changes for all modules
+	// pull changes for all selected modules
 	modules := pull.moduleCollectionProvider()
-	for _, module := range modules.Collection {
+	selectedModules, skippedModules := base.SelectModules(modules, arguments)
+	pull.SkippedModules.Add(skippedModules)
+
+	for _, module := range selectedModules {
 		// pull changes in sub-module
 		if err := gitPull(module.Directory()); err != nil {
 			ui.Message("Error while updating module %s:\n%s", module, err)
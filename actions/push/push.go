@@ -16,6 +16,8 @@ const (
 )
 
 type Push struct {
+	base.SkippedModules
+
 	baseDirectory            string
 	moduleCollectionProvider base.ModulesProviderFunc
 }
@@ -45,9 +47,12 @@ func (push *Push) DryRun(arguments []string) {
 
 func (push *Push) execute(executeADryRunOnly bool, arguments []string) {
 
-	// push all submodules
+	// push all selected submodules
 	modules := push.moduleCollectionProvider()
-	for _, module := range modules.Collection {
+	selectedModules, skippedModules := base.SelectModules(modules, arguments)
+	push.SkippedModules.Add(skippedModules)
+
+	for _, module := range selectedModules {
 
 		ui.Message("Pushing changes in sub-module %q.", module)
 
@@ -8,6 +8,8 @@ import (
 	"flag"
 	"fmt"
 	"github.com/andreaskoch/dotman/actions"
+	"github.com/andreaskoch/dotman/actions/base"
+	"github.com/andreaskoch/dotman/modules"
 	"github.com/andreaskoch/dotman/ui"
 	"os"
 	"strings"
@@ -24,14 +26,26 @@ var (
 	whatIfFlagName        = "whatif"
 	whatIfFlagDescription = "Enable the dry-run mode. Only print out what would happen."
 
-	// module filter argument
-	moduleFilterExpressionName        = "filter"
-	moduleFilterExpressionDescription = "You can add a module filter expression to the import, list, changes and deploy commands."
+	// the depth flag
+	depthFlag            = modules.DefaultMaxDepth
+	depthFlagName        = "depth"
+	depthFlagDescription = "The maximum depth of module directories below the current directory."
+
+	// the force flag
+	forceFlag            = false
+	forceFlagName        = base.ForceFlagName
+	forceFlagDescription = "Use modules whose module files contain errors (the broken lines are skipped)."
+
+	// module selector argument
+	moduleSelectorName        = "selector"
+	moduleSelectorDescription = "Select modules by name, regular expression (re:<expression>), tag (@<tag>) or exclude them (!<module>)."
 )
 
 func init() {
 	// define flags
 	flag.BoolVar(&whatIfFlag, whatIfFlagName, whatIfFlag, whatIfFlagDescription)
+	flag.IntVar(&depthFlag, depthFlagName, depthFlag, depthFlagDescription)
+	flag.BoolVar(&forceFlag, forceFlagName, forceFlag, forceFlagDescription)
 
 }
 
@@ -56,7 +70,12 @@ func main() {
 		commandArguments = commandLineArguments[1:]
 	}
 
-	if command := actions.Get(workingDirectory, commandName); command != nil {
+	options := actions.Options{
+		MaxDepth: depthFlag,
+		Force:    forceFlag,
+	}
+
+	if command := actions.Get(workingDirectory, commandName, options); command != nil {
 
 		if whatIfFlag {
 			ui.Message("Performing a dry-run. No changes will we applied to the system.")
@@ -66,16 +85,25 @@ func main() {
 
 		}
 
-		os.Exit(0)
+		// actions which have skipped modules because of errors exit with the status code 1
+		exitCode := 0
+		if exitCoder, isExitCoder := command.(actions.ExitCoder); isExitCoder {
+			exitCode = exitCoder.ExitCode()
+		}
+
+		os.Exit(exitCode)
 	}
 
 	// print the help if no command was recognized
 	usage()
 }
 
+// getCommandLineArguments returns the command name and its arguments. The global
+// flags in front of the command have already been parsed; a -whatif flag
+// after the command is ignored.
 func getCommandLineArguments() []string {
 	args := make([]string, 0)
-	for _, arg := range os.Args[1:] {
+	for _, arg := range flag.Args() {
 		whatIfFlagArg := fmt.Sprintf("-%s", whatIfFlagName)
 		if strings.HasPrefix(arg, whatIfFlagArg) {
 			continue
@@ -97,7 +125,7 @@ var usage = func() {
 	ui.Message("")
 
 	// usage
-	ui.Message("usage: %s [-whatif] <command> [<filter>]", getApplicationName())
+	ui.Message("usage: %s [-whatif] [-force] [-depth <n>] <command> [<selector>...]", getApplicationName())
 	ui.Message("")
 
 	// commands
@@ -110,11 +138,13 @@ var usage = func() {
 	ui.Message("")
 	ui.Message("Options:")
 	ui.Message("    %s %s  %s", whatIfFlagName, getActionSpacer(whatIfFlagName), whatIfFlagDescription)
+	ui.Message("    %s %s  %s", forceFlagName, getActionSpacer(forceFlagName), forceFlagDescription)
+	ui.Message("    %s %s  %s", depthFlagName, getActionSpacer(depthFlagName), depthFlagDescription)
 
 	// args
 	ui.Message("")
 	ui.Message("Arguments:")
-	ui.Message("    %s %s  %s", moduleFilterExpressionName, getActionSpacer(moduleFilterExpressionName), moduleFilterExpressionDescription)
+	ui.Message("    %s %s  %s", moduleSelectorName, getActionSpacer(moduleSelectorName), moduleSelectorDescription)
 
 	// source code
 	ui.Message("")
@@ -0,0 +1,143 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"fmt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// the directive which maps the files of the module which follow the naming convention
+	AutoDirective = "auto"
+
+	// the directory the "auto" directive maps the files to if no other directory is supplied
+	DefaultAutoTarget = "~"
+
+	// a "dot_" prefix stands for a dot in every part of a path ("dot_config/dot_foo" → ".config/.foo")
+	autoDotPrefix = "dot_"
+
+	// an underscore prefix stands for a dot in the first part of a path ("_bashrc" → ".bashrc")
+	autoUnderscorePrefix = "_"
+)
+
+// newAutoEntry creates an entry which maps every file below the supplied module directory
+// whose path starts with "dot_" or "_" to the same path with a dot below the target directory.
+func newAutoEntry(moduleDirectory string, statement *Statement, variables variables) (*pathMapEntry, error) {
+
+	target := normalizePathSpecification(statement.Auto)
+	targetDirectory, err := expandTargetPath(target, variables)
+	if err != nil {
+		return nil, errorAt(err, statement.Auto)
+	}
+
+	warnings := make(ParseErrors, 0)
+	if warning := getTargetWarning(target, variables); warning != nil {
+		warnings = append(warnings, warning)
+	}
+
+	// "auto <target>" might be meant as an entry for a file named "auto"
+	if statement.Auto != DefaultAutoTarget && !IsManifest(statement.file) && fs.PathExists(filepath.Join(moduleDirectory, AutoDirective)) {
+		warnings = append(warnings, &ParseError{
+			Message: fmt.Sprintf("The module contains a file named %q but the line is read as the %q directive.", AutoDirective, AutoDirective),
+			Hint:    fmt.Sprintf("To map the file, separate the columns with two spaces (\"%s  %s\").", AutoDirective, statement.Auto),
+			token:   AutoDirective,
+		})
+	}
+
+	return &pathMapEntry{
+		source:    moduleDirectory,
+		target:    targetDirectory,
+		options:   newOptions(),
+		statement: statement,
+		warnings:  warnings,
+		isAuto:    true,
+	}, nil
+}
+
+// findAutoMatches returns a match for every file in the module which follows the naming convention.
+// Each file gets a match of its own, so entries for single files can override the automatic ones.
+func (entry *pathMapEntry) findAutoMatches() []pathMatch {
+
+	moduleDirectory, targetDirectory := entry.source, entry.target
+	if entry.isReversed {
+		moduleDirectory, targetDirectory = entry.target, entry.source
+	}
+
+	matches := make([]pathMatch, 0)
+	filepath.Walk(moduleDirectory, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == moduleDirectory {
+			return nil // skip unreadable entries
+		}
+
+		relativePath, err := filepath.Rel(moduleDirectory, path)
+		if err != nil {
+			return nil
+		}
+
+		// skip the top-level files and directories which don't follow the convention
+		targetPath, followsConvention := getAutoTargetPath(relativePath)
+		if !followsConvention {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		match := pathMatch{path, filepath.Join(targetDirectory, targetPath)}
+		if entry.isReversed {
+			match = pathMatch{match.target, match.source}
+		}
+
+		matches = append(matches, match)
+		return nil
+	})
+
+	return matches
+}
+
+// getAutoTargetPath returns the target of the supplied path (relative to the module directory)
+// if the path follows the naming convention ("dot_config/foo" → ".config/foo", "_bashrc" → ".bashrc").
+func getAutoTargetPath(relativePath string) (string, bool) {
+
+	names := strings.Split(relativePath, string(os.PathSeparator))
+	for index, name := range names {
+		switch {
+
+		case strings.HasPrefix(name, autoDotPrefix) && len(name) > len(autoDotPrefix):
+			names[index] = "." + strings.TrimPrefix(name, autoDotPrefix)
+
+		case index == 0 && strings.HasPrefix(name, autoUnderscorePrefix) && len(name) > len(autoUnderscorePrefix):
+			names[index] = "." + strings.TrimPrefix(name, autoUnderscorePrefix)
+
+		case index == 0:
+			return "", false
+
+		}
+	}
+
+	return filepath.Join(names...), true
+}
+
+// isOverriddenBy checks if the supplied instruction of an "auto" directive maps a file
+// which is also mapped by one of the supplied instructions of the other entries (the file
+// in the module or its target is the same as or inside of the other file or directory).
+func isOverriddenBy(instruction *Instruction, explicitInstructions []*Instruction) bool {
+	for _, explicitInstruction := range explicitInstructions {
+		if fs.IsSameOrInside(instruction.Source(), explicitInstruction.Source()) || fs.IsSameOrInside(instruction.Target(), explicitInstruction.Target()) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGetAutoTargetPath(t *testing.T) {
+
+	tests := []struct {
+		path              string
+		target            string
+		followsConvention bool
+	}{
+		{"_bashrc", ".bashrc", true},
+		{"dot_bashrc", ".bashrc", true},
+		{"dot_config/nvim/init.lua", ".config/nvim/init.lua", true},
+		{"dot_config/dot_foo/bar", ".config/.foo/bar", true},
+		{"_config/_foo", ".config/_foo", true},
+		{"dot_config/dot_", ".config/dot_", true},
+		{"bashrc", "", false},
+		{"config/dot_foo", "", false},
+		{"_", "", false},
+		{"dot_", "", false},
+		{"README.md", "", false},
+	}
+
+	for _, test := range tests {
+		target, followsConvention := getAutoTargetPath(filepath.FromSlash(test.path))
+		if filepath.ToSlash(target) != test.target || followsConvention != test.followsConvention {
+			t.Errorf("getAutoTargetPath(%q) = %q, %v, expected %q, %v", test.path, target, followsConvention, test.target, test.followsConvention)
+		}
+	}
+}
+
+func TestAutoEntriesAreOverridden(t *testing.T) {
+
+	homeDirectory := getTestHomeDirectory(t)
+
+	tests := []struct {
+		text     string
+		expected []string // the sources (relative to the module) and targets (relative to the home directory)
+	}{
+		{"auto", []string{"_bashrc → .bashrc", "_zshrc → .zshrc", "dot_config/app/config → .config/app/config", "dot_config/app/themes/dark → .config/app/themes/dark"}},
+		{"auto\n_zshrc  ~/.zshrc.local", []string{"_bashrc → .bashrc", "_zshrc → .zshrc.local", "dot_config/app/config → .config/app/config", "dot_config/app/themes/dark → .config/app/themes/dark"}},
+		{"auto\nzshrc  ~/.zshrc", []string{"_bashrc → .bashrc", "dot_config/app/config → .config/app/config", "dot_config/app/themes/dark → .config/app/themes/dark", "zshrc → .zshrc"}},
+		{"auto\ndot_config/app  ~/.app", []string{"_bashrc → .bashrc", "_zshrc → .zshrc", "dot_config/app → .app"}},
+		{"auto\nthemes  ~/.config/app/themes", []string{"_bashrc → .bashrc", "_zshrc → .zshrc", "dot_config/app/config → .config/app/config", "themes → .config/app/themes"}},
+		{"auto ~/other\n_bashrc  ~/.bashrc", []string{"_bashrc → .bashrc", "_zshrc → other/.zshrc", "dot_config/app/config → other/.config/app/config", "dot_config/app/themes/dark → other/.config/app/themes/dark"}},
+	}
+
+	for _, test := range tests {
+		directory := newTestModule(t, map[string]string{
+			"dotman":                     test.text,
+			"_bashrc":                    "b",
+			"_zshrc":                     "z",
+			"zshrc":                      "z",
+			"themes/light":               "l",
+			"dot_config/app/config":      "c",
+			"dot_config/app/themes/dark": "d",
+			"README.md":                  "r",
+		})
+
+		pathMap, err := NewPathMap(filepath.Join(directory, "dotman"))
+		if err != nil {
+			t.Errorf("NewPathMap(%q) failed: %s", test.text, err)
+			continue
+		}
+
+		mappings := make([]string, 0)
+		for _, instruction := range pathMap.GetInstructions() {
+			source, _ := filepath.Rel(directory, instruction.Source())
+			target, _ := filepath.Rel(homeDirectory, instruction.Target())
+			mappings = append(mappings, filepath.ToSlash(source)+" → "+filepath.ToSlash(target))
+		}
+
+		sort.Strings(mappings)
+		if !reflect.DeepEqual(mappings, test.expected) {
+			t.Errorf("%q maps %q, expected %q", test.text, mappings, test.expected)
+		}
+	}
+}
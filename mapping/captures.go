@@ -0,0 +1,154 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"fmt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// $1 or ${1} in a target path
+	captureReferencePattern = regexp.MustCompile(`\$(\d+)|\$\{(\d+)\}`)
+)
+
+func hasCaptureReferences(path string) bool {
+	return captureReferencePattern.MatchString(path)
+}
+
+// checkCaptureReferences returns an error if the supplied target refers to a capture group
+// which the pattern expression doesn't have (or if the entry has no pattern at all).
+func checkCaptureReferences(target string, expression *regexp.Regexp) error {
+	for _, submatches := range captureReferencePattern.FindAllStringSubmatch(target, -1) {
+		reference := submatches[0]
+
+		if expression == nil {
+			return &ParseError{
+				Message: fmt.Sprintf("The target refers to the capture group %q but the entry has no pattern.", reference),
+				Hint:    "Add a regular expression or glob pattern after the target path.",
+				token:   reference,
+			}
+		}
+
+		if number, err := strconv.Atoi(submatches[1] + submatches[2]); err != nil || number > expression.NumSubexp() {
+			return &ParseError{
+				Message: fmt.Sprintf("The target refers to the capture group %q which the pattern does not have.", reference),
+				Hint:    "Capture groups are numbered from left to right, starting with $1 ($0 is the whole match).",
+				token:   reference,
+			}
+		}
+	}
+
+	return nil
+}
+
+// pathMatch is a file or directory below the source of a pattern entry
+// together with the path it is mapped to.
+type pathMatch struct {
+	source string
+	target string
+}
+
+// getPatternExpression returns the expression which is matched against the
+// names (regular expressions) or relative paths (glob patterns) below the source.
+func (entry *pathMapEntry) getPatternExpression() *regexp.Regexp {
+	if entry.glob != nil {
+		return entry.glob.Expression()
+	}
+
+	return entry.pattern
+}
+
+// findMatches returns all files and directories below the supplied module directory
+// which match the pattern together with their targets. The target is either the
+// relative path below the target directory or the target template with the
+// capture groups of the match.
+func (entry *pathMapEntry) findMatches(directory, target string) []pathMatch {
+
+	relativePaths := make([]string, 0)
+	if entry.glob != nil {
+		relativePaths = entry.glob.Find(directory)
+	} else if directoryEntries, err := ioutil.ReadDir(directory); err == nil {
+		for _, directoryEntry := range directoryEntries {
+			if entry.pattern.MatchString(directoryEntry.Name()) {
+				relativePaths = append(relativePaths, directoryEntry.Name())
+			}
+		}
+	}
+
+	expression := entry.getPatternExpression()
+	isTemplate := hasCaptureReferences(target)
+
+	// regexp.Expand reads "$1rc" as the group named "1rc", so all references are
+	// written as "${1}" (a reference always ends after its digits)
+	template := captureReferencePattern.ReplaceAllString(target, "$${${1}${2}}")
+
+	matches := make([]pathMatch, 0, len(relativePaths))
+	for _, relativePath := range relativePaths {
+		sourcePath := filepath.Join(directory, filepath.FromSlash(relativePath))
+
+		targetPath := filepath.Join(target, filepath.FromSlash(relativePath))
+		if isTemplate {
+			submatches := expression.FindStringSubmatchIndex(relativePath)
+			targetPath = filepath.Clean(string(expression.ExpandString(nil, template, relativePath, submatches)))
+		}
+
+		matches = append(matches, pathMatch{sourcePath, targetPath})
+	}
+
+	return matches
+}
+
+// findReversedMatches returns the matches of a reversed entry whose target contains capture
+// references. The targets can match far more files than the pattern (e.g. "~/.$1"), so only
+// the files which already exist in the module are mapped back.
+func (entry *pathMapEntry) findReversedMatches() []pathMatch {
+
+	// the source of a reversed entry is the target template, the target is the module directory
+	moduleDirectory := entry.target
+	targetTemplate := entry.source
+
+	matches := make([]pathMatch, 0)
+	for _, match := range entry.findMatches(moduleDirectory, targetTemplate) {
+		matches = append(matches, pathMatch{match.target, match.source})
+	}
+
+	return matches
+}
+
+// getUnmatchedPatternWarning returns a warning if the entry has a pattern which matches
+// nothing in the existing source directory of the module (e.g. a misspelled option).
+func (entry *pathMapEntry) getUnmatchedPatternWarning() *ParseError {
+
+	if !entry.HasPattern() || entry.isAuto {
+		return nil
+	}
+
+	moduleDirectory, target := entry.source, entry.target
+	if entry.isReversed {
+		moduleDirectory, target = entry.target, entry.source
+	}
+
+	if !fs.PathExists(moduleDirectory) || len(entry.findMatches(moduleDirectory, target)) > 0 {
+		return nil
+	}
+
+	warning := &ParseError{
+		Message: fmt.Sprintf("The pattern %q matches nothing in %q.", entry.statement.Pattern, moduleDirectory),
+		token:   entry.statement.Pattern,
+	}
+
+	// patterns without wildcards are often misspelled options
+	if entry.glob != nil && entry.glob.Expression().NumSubexp() == 0 {
+		warning.Hint = fmt.Sprintf("If it is meant to be an option: the known options are %s.", strings.Join(knownOptions, ", "))
+	}
+
+	return warning
+}
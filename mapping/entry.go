@@ -7,63 +7,169 @@ package mapping
 import (
 	"fmt"
 	"github.com/andreaskoch/dotman/util/fs"
+	"github.com/andreaskoch/dotman/util/glob"
 	"path/filepath"
 	"regexp"
 	"strings"
 )
 
+const (
+	// the prefix of patterns which are regular expressions instead of glob patterns
+	RegexPatternPrefix = "re:"
+
+	// the optional prefix of glob patterns (for patterns which would be read as an option, e.g. "glob:link")
+	GlobPatternPrefix = "glob:"
+)
+
 var (
-	// the white space between the source path, target path and pattern
-	pathMapEntrySeparatorPattern = regexp.MustCompile(`(?:\s{2,}|\t+)`)
+	// glob patterns which were probably meant as regular expressions (e.g. "^.*\.conf$" or "(vim|nvim)rc")
+	regexLikePattern = regexp.MustCompile(`^\^|[^\\]\$$|\\[.dws]|\.\+|\(.*\|.*\)`)
 )
 
-func newPathMapEntry(baseDirectory, dotmanPathMapEntry string) (*pathMapEntry, error) {
+func newPathMapEntry(baseDirectory string, statement *Statement, variables variables) (*pathMapEntry, error) {
+
+	// source path (relative to the module directory)
+	source := normalizePathSpecification(statement.Source)
+	expandedSource, err := variables.expand(source)
+	if err != nil {
+		return nil, errorAt(err, statement.Source)
+	}
 
-	// find source and target path matching in the supplied map entry
-	entries := pathMapEntrySeparatorPattern.Split(dotmanPathMapEntry, -1)
-	if len(entries) < 2 {
-		return nil, fmt.Errorf("%q is not a valid path map entry. A path map entry should conists if a source and target path and optionally a pattern, all separated by some whitespace.", dotmanPathMapEntry)
+	if expandedSource != source && filepath.IsAbs(expandedSource) {
+		return nil, errorAt(fmt.Errorf("The source %q must be a path in the module but the variables turn it into %q.", statement.Source, expandedSource), statement.Source)
 	}
 
-	// source path
-	sourcePath := filepath.Join(baseDirectory, normalizePathSpecification(entries[0]))
+	// a source can leave the module explicitly (e.g. "../shared/rc") but not only because of a variable
+	sourcePath := filepath.Join(baseDirectory, expandedSource)
+	leavesModule := !fs.IsSameOrInside(filepath.Join(baseDirectory, source), baseDirectory)
+	if expandedSource != source && !leavesModule && !fs.IsSameOrInside(sourcePath, baseDirectory) {
+		return nil, errorAt(fmt.Errorf("The source %q must be a path in the module but the variables turn it into %q which is outside of the module.", statement.Source, expandedSource), statement.Source)
+	}
 
 	// target path
-	targetPath := expandPathVariables(normalizePathSpecification(entries[1]))
+	target := normalizePathSpecification(statement.Target)
+	targetPath, err := expandTargetPath(target, variables)
+	if err != nil {
+		return nil, errorAt(err, statement.Target)
+	}
+
+	warnings := make(ParseErrors, 0)
+	if warning := getTargetWarning(target, variables); warning != nil {
+		warnings = append(warnings, warning)
+	}
+
+	// the options
+	options := newOptions()
+	optionColumns, err := statement.getOptionColumns()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, option := range optionColumns {
+		if err := options.set(option); err != nil {
+			return nil, errorAt(err, option)
+		}
+	}
 
-	// glob pattern
+	// the pattern
 	var pattern *regexp.Regexp
-	if len(entries) == 3 {
-		patternText := strings.TrimSpace(entries[2])
-		parsedPattern, err := getPattern(patternText)
+	var globPattern *glob.Glob
+	switch {
+
+	// regular expressions are matched against the names of the source entries
+	case strings.HasPrefix(statement.Pattern, RegexPatternPrefix):
+		parsedPattern, err := getPattern(strings.TrimPrefix(statement.Pattern, RegexPatternPrefix))
 		if err != nil {
-			return nil, fmt.Errorf("%q is not a valid regular expression. Error: %s", patternText, err)
+			return nil, errorAt(fmt.Errorf("%q is not a valid regular expression. Error: %s", statement.Pattern, err), statement.Pattern)
 		}
 
 		pattern = parsedPattern
+
+	// glob patterns are matched against the paths relative to the source
+	case statement.Pattern != "":
+		globText := strings.TrimPrefix(statement.Pattern, GlobPatternPrefix)
+		parsedGlob, err := glob.Compile(globText)
+		if err != nil {
+			return nil, errorAt(err, statement.Pattern)
+		}
+
+		if regexLikePattern.MatchString(globText) {
+			warnings = append(warnings, &ParseError{
+				Message: fmt.Sprintf("The pattern %q is a glob pattern but looks like a regular expression.", statement.Pattern),
+				Hint:    fmt.Sprintf("Write it as \"%s%s\" to use it as a regular expression.", RegexPatternPrefix, globText),
+				token:   statement.Pattern,
+			})
+		}
+
+		globPattern = parsedGlob
+
+	}
+
+	// the capture groups the target refers to
+	expression := pattern
+	if globPattern != nil {
+		expression = globPattern.Expression()
+	}
+
+	if err := checkCaptureReferences(statement.Target, expression); err != nil {
+		return nil, err
+	}
+
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	// templates are rendered with the variables which are declared up to this entry
+	var templateData map[string]string
+	if options.Template {
+		templateData = variables.getTemplateData()
 	}
 
 	return &pathMapEntry{
-		source:  sourcePath,
-		target:  targetPath,
-		pattern: pattern,
+		source:       sourcePath,
+		target:       targetPath,
+		pattern:      pattern,
+		glob:         globPattern,
+		options:      options,
+		templateData: templateData,
+		statement:    statement,
+		warnings:     warnings,
 	}, nil
 }
 
 type pathMapEntry struct {
-	source  string
-	target  string
-	pattern *regexp.Regexp
+	source       string
+	target       string
+	pattern      *regexp.Regexp
+	glob         *glob.Glob
+	options      *Options
+	templateData map[string]string
+
+	// the file and line (or manifest entry) of the statement
+	position string
+
+	// the statement the entry has been created from
+	statement *Statement
+
+	// the problems of the statement which don't prevent its use
+	warnings ParseErrors
+
+	// entries of the "auto" directive map the module directory to the target directory
+	isAuto bool
 
 	isReversed bool
 }
 
 func (entry *pathMapEntry) String() string {
+	if entry.glob != nil {
+		return fmt.Sprintf("%s → %s (Pattern: %s)", entry.source, entry.target, entry.glob)
+	}
+
 	return fmt.Sprintf("%s → %s (Pattern: %s)", entry.source, entry.target, entry.pattern)
 }
 
 func (entry *pathMapEntry) HasPattern() bool {
-	return entry.pattern != nil
+	return entry.pattern != nil || entry.glob != nil
 }
 
 func (entry *pathMapEntry) IsReversed() bool {
@@ -85,22 +191,32 @@ func (entry *pathMapEntry) Reverse() *pathMapEntry {
 
 func (entry *pathMapEntry) GetInstructions() []*Instruction {
 
+	// one instruction for every file which follows the naming convention
+	if entry.isAuto {
+		instructions := make([]*Instruction, 0)
+		for _, match := range entry.findAutoMatches() {
+			instructions = append(instructions, newInstruction(entry, match.source, match.target))
+		}
+
+		return instructions
+	}
+
 	// single instruction
 	if !entry.HasPattern() {
-		return []*Instruction{newInstruction(entry.source, entry.target)}
+		return []*Instruction{newInstruction(entry, entry.source, entry.target)}
 	}
 
 	// multiple instructions
-	instructions := make([]*Instruction, 0)
-
-	// find all file which match the pattern
-	sourceEntries := fs.GetMatchingDirectoryEntries(entry.source, entry.pattern)
-	for _, sourceEntry := range sourceEntries {
-		sourceEntryName := filepath.Base(sourceEntry)
-		targetEntry := filepath.Join(entry.target, sourceEntryName)
+	matches := make([]pathMatch, 0)
+	if entry.isReversed && hasCaptureReferences(entry.source) {
+		matches = entry.findReversedMatches()
+	} else {
+		matches = entry.findMatches(entry.source, entry.target)
+	}
 
-		// add a new instruction
-		instructions = append(instructions, newInstruction(sourceEntry, targetEntry))
+	instructions := make([]*Instruction, 0, len(matches))
+	for _, match := range matches {
+		instructions = append(instructions, newInstruction(entry, match.source, match.target))
 	}
 
 	return instructions
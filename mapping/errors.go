@@ -0,0 +1,123 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A ParseError describes a statement of a dotman file or dotman.json manifest which cannot be used.
+type ParseError struct {
+	File string
+
+	// the line of the statement in a dotman file (0 if unknown)
+	Line int
+
+	// the number of the entry in a dotman.json manifest (0 if unknown)
+	Entry int
+
+	// the position of the problem in the line (0 if unknown)
+	Column int
+
+	Message string
+
+	// a suggestion how to fix the problem (optional)
+	Hint string
+
+	// the text of the statement the problem refers to (used to determine the column)
+	token string
+}
+
+func (err *ParseError) Error() string {
+	position := formatPosition(err.File, err.Line, err.Entry, err.Column)
+
+	message := err.Message
+	if err.Hint != "" {
+		message += " " + err.Hint
+	}
+
+	if position == "" {
+		return message
+	}
+
+	return position + ": " + message
+}
+
+// formatPosition returns the supplied position in a file (e.g. "vim/dotman:3:12" or "vim/dotman.json: entry 2").
+func formatPosition(file string, line, entry, column int) string {
+	position := file
+	switch {
+
+	case line > 0 && column > 0:
+		position += fmt.Sprintf(":%d:%d", line, column)
+
+	case line > 0:
+		position += fmt.Sprintf(":%d", line)
+
+	case entry > 0:
+		position += fmt.Sprintf(": entry %d", entry)
+
+	}
+
+	return position
+}
+
+// ParseErrors contains all problems of a dotman file and the files it includes.
+type ParseErrors []*ParseError
+
+func (errors ParseErrors) Error() string {
+	messages := make([]string, 0, len(errors))
+	for _, err := range errors {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// errorAt returns the supplied error as a parse error which refers to the supplied text of a statement.
+func errorAt(err error, token string) *ParseError {
+	parseError, isParseError := err.(*ParseError)
+	if !isParseError {
+		parseError = &ParseError{Message: err.Error()}
+	}
+
+	if parseError.token == "" {
+		parseError.token = token
+	}
+
+	return parseError
+}
+
+// NewJSONError returns the supplied error of the JSON decoder as a parse error
+// with the line and column of the problem in the supplied file content.
+func NewJSONError(path string, content []byte, err error) *ParseError {
+
+	offset := int64(-1)
+	switch jsonError := err.(type) {
+
+	case *json.SyntaxError:
+		offset = jsonError.Offset
+
+	case *json.UnmarshalTypeError:
+		offset = jsonError.Offset
+
+	}
+
+	parseError := &ParseError{
+		File:    path,
+		Message: fmt.Sprintf("The file is not a valid manifest. %s", err),
+	}
+
+	if offset >= 0 && offset <= int64(len(content)) {
+		before := content[:offset]
+		parseError.Line = bytes.Count(before, []byte("\n")) + 1
+		parseError.Column = len([]rune(string(before[bytes.LastIndex(before, []byte("\n"))+1:])))
+	}
+
+	return parseError
+}
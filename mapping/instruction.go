@@ -4,16 +4,25 @@
 
 package mapping
 
-func newInstruction(source, target string) *Instruction {
+// newInstruction creates an instruction for the supplied source and target path of a path map entry.
+func newInstruction(entry *pathMapEntry, source, target string) *Instruction {
 	return &Instruction{
-		sourcePath: source,
-		targetPath: target,
+		sourcePath:   source,
+		targetPath:   target,
+		options:      entry.options,
+		templateData: entry.templateData,
+		isReversed:   entry.isReversed,
+		position:     entry.position,
 	}
 }
 
 type Instruction struct {
-	sourcePath string
-	targetPath string
+	sourcePath   string
+	targetPath   string
+	options      *Options
+	templateData map[string]string
+	isReversed   bool
+	position     string
 }
 
 func (instruction *Instruction) Source() string {
@@ -23,3 +32,30 @@ func (instruction *Instruction) Source() string {
 func (instruction *Instruction) Target() string {
 	return instruction.targetPath
 }
+
+// Options returns the options of the path map entry the instruction belongs to.
+func (instruction *Instruction) Options() *Options {
+	return instruction.options
+}
+
+// IsEncrypted returns true if the files in the module are stored encrypted.
+// For reversed instructions the target is encrypted, otherwise the source.
+func (instruction *Instruction) IsEncrypted() bool {
+	return instruction.options.Encrypted
+}
+
+// IsReversed returns true if the instruction copies from the target to the module.
+func (instruction *Instruction) IsReversed() bool {
+	return instruction.isReversed
+}
+
+// Position returns the file and line (or manifest entry) of the path map entry
+// the instruction belongs to (e.g. "vim/dotman:3").
+func (instruction *Instruction) Position() string {
+	return instruction.position
+}
+
+// TemplateData returns the variables which are available in templates.
+func (instruction *Instruction) TemplateData() map[string]string {
+	return instruction.templateData
+}
@@ -6,12 +6,13 @@ package mapping
 
 import (
 	"fmt"
-	"github.com/andreaskoch/dotman/ui"
 	"github.com/andreaskoch/dotman/util/fs"
-	"os"
 	"path/filepath"
 )
 
+// NewPathMap reads the supplied dotman file (or dotman.json manifest) and all files it includes.
+// If some statements cannot be used the path map contains all other entries and the error
+// is a list of ParseErrors.
 func NewPathMap(sourceFile string) (*PathMap, error) {
 
 	// check if the source file exists
@@ -19,50 +20,50 @@ func NewPathMap(sourceFile string) (*PathMap, error) {
 		return nil, fmt.Errorf("Cannot create a path map because the specified dotfile %q does not exist.", sourceFile)
 	}
 
-	// open the dotman file
-	file, err := os.Open(sourceFile)
-	if err != nil {
-		return nil, err // unable to read the supplied source file
+	// read the dotman file and all files it includes
+	parser := newParser(filepath.Dir(sourceFile))
+	if err := parser.parseFile(filepath.Clean(sourceFile)); err != nil {
+		return nil, err
 	}
 
-	// determine the path map directory
-	directory := filepath.Dir(sourceFile)
-
-	pathMapEntries := make([]*pathMapEntry, 0)
-
-	// read in the lines of the dotman file and create path map entries from it
-	lines := fs.GetLines(file)
-	for lineNumber, line := range lines {
-
-		// ignore white space and comments
-		if isEmptyLine(line) || isComment(line) {
-			continue
-		}
-
-		// create a path map entry from the line
-		pathMapEntry, err := newPathMapEntry(directory, line)
-		if err != nil {
-			ui.Message("Line %d: %s", lineNumber+1, err)
-			continue
-		}
+	pathMap := &PathMap{
+		directory: parser.directory,
+		entries:   parser.entries,
+		warnings:  parser.warnings,
+	}
 
-		// append the path map entry to the list
-		pathMapEntries = append(pathMapEntries, pathMapEntry)
+	if len(parser.errors) > 0 {
+		return pathMap, parser.errors
 	}
 
-	return &PathMap{
-		directory: directory,
-		entries:   pathMapEntries,
-	}, nil
+	return pathMap, nil
 }
 
 type PathMap struct {
 	directory string
 	entries   []*pathMapEntry
 
+	// problems of the statements which don't prevent their use
+	warnings ParseErrors
+
 	isReversed bool
 }
 
+// Warnings returns the problems of the statements which don't prevent their use
+// (e.g. targets which look as if they were relative to the current directory or
+// patterns which match nothing in the module).
+func (pathMap *PathMap) Warnings() ParseErrors {
+
+	warnings := append(make(ParseErrors, 0), pathMap.warnings...)
+	for _, entry := range pathMap.entries {
+		if warning := entry.getUnmatchedPatternWarning(); warning != nil {
+			warnings = append(warnings, entry.statement.newError(warning))
+		}
+	}
+
+	return warnings
+}
+
 func (pathMap *PathMap) IsReversed() bool {
 	return pathMap.isReversed
 }
@@ -78,13 +79,43 @@ func (pathMap *PathMap) Reverse() *PathMap {
 	return pathMap
 }
 
+// GetUnmatchedEntries returns an instruction for the source and target path of every
+// entry with a pattern which matches no file or folder.
+func (pathMap *PathMap) GetUnmatchedEntries() []*Instruction {
+
+	instructions := make([]*Instruction, 0)
+	for _, entry := range pathMap.entries {
+		if entry.HasPattern() && len(entry.GetInstructions()) == 0 {
+			instructions = append(instructions, newInstruction(entry, entry.source, entry.target))
+		}
+	}
+
+	return instructions
+}
+
 func (pathMap *PathMap) GetInstructions() []*Instruction {
 
 	instructions := make([]*Instruction, 0)
 
 	// get the instructions for all entries
 	for _, entry := range pathMap.entries {
-		instructions = append(instructions, entry.GetInstructions()...)
+		if !entry.isAuto {
+			instructions = append(instructions, entry.GetInstructions()...)
+		}
+	}
+
+	// the entries in the dotman file override the "auto" directive
+	explicitInstructions := instructions
+	for _, entry := range pathMap.entries {
+		if !entry.isAuto {
+			continue
+		}
+
+		for _, instruction := range entry.GetInstructions() {
+			if !isOverriddenBy(instruction, explicitInstructions) {
+				instructions = append(instructions, instruction)
+			}
+		}
 	}
 
 	return instructions
@@ -0,0 +1,216 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"github.com/andreaskoch/dotman/util/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestModule writes the supplied files (relative path → content) to a
+// temporary module directory and returns the directory.
+func newTestModule(t *testing.T, files map[string]string) string {
+	directory := t.TempDir()
+	for path, content := range files {
+		path = filepath.Join(directory, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return directory
+}
+
+func TestPathMapPatternWarnings(t *testing.T) {
+
+	tests := []struct {
+		line            string
+		expectedWarning string // empty if no warning is expected
+	}{
+		{"dir  ~/dir  *.conf", ""},
+		{"dir  ~/dir  re:^.*\\.conf$", ""},
+		{"dir  ~/dir  .*", ""},
+		{"dir  ~/dir  c++", ""},
+		{"dir  ~/dir  ^.*\\.conf$", "looks like a regular expression"},
+		{"dir  ~/dir  (a|b)", "looks like a regular expression"},
+		{"dir  ~/dir  *.none", "matches nothing"},
+		{"file  ~/file  bogusopt", "If it is meant to be an option"},
+		{"file  ~/file  bogus=1", "If it is meant to be an option"},
+		{"dir  ~/dir  glob:a.conf", ""},
+		{"missing  ~/missing  *.conf", ""},
+		{"auto ~/.autorc", "is read as the \"auto\" directive"},
+		{"auto  ~/.autorc", ""},
+		{"auto", ""},
+		{"include other", "is read as the \"include\" directive"},
+		{"include  ~/.include", ""},
+	}
+
+	for _, test := range tests {
+		directory := newTestModule(t, map[string]string{
+			"dotman":          test.line,
+			"dir/a.conf":      "a",
+			"dir/.hidden":     "h",
+			"dir/c++/main.cc": "c",
+			"file":            "f",
+			"auto":            "a",
+			"include":         "i",
+			"other":           "",
+		})
+
+		pathMap, err := NewPathMap(filepath.Join(directory, "dotman"))
+		if err != nil {
+			t.Errorf("NewPathMap(%q) failed: %s", test.line, err)
+			continue
+		}
+
+		warnings := pathMap.Warnings()
+		if test.expectedWarning == "" {
+			if len(warnings) > 0 {
+				t.Errorf("%q has the warnings %q, expected none", test.line, warnings)
+			}
+
+			continue
+		}
+
+		if !strings.Contains(warnings.Error(), test.expectedWarning) {
+			t.Errorf("%q has the warnings %q, expected %q", test.line, warnings, test.expectedWarning)
+		}
+	}
+}
+
+func TestPathMapCaptureReferences(t *testing.T) {
+
+	homeDirectory := getTestHomeDirectory(t)
+
+	tests := []struct {
+		line     string
+		expected []string // the targets relative to the home directory
+	}{
+		{"links  ~/.$1  re:^(.+)\\.symlink$", []string{".bash", ".vim"}},
+		{"links  ~/.$1rc  re:^(.+)\\.symlink$", []string{".bashrc", ".vimrc"}},
+		{"links  ~/.${1}rc  re:^(.+)\\.symlink$", []string{".bashrc", ".vimrc"}},
+		{"links  ~/.${1}0  re:^(.+)\\.symlink$", []string{".bash0", ".vim0"}},
+		{"links  ~/$2/$1  re:^(.+)\\.(symlink)$", []string{"symlink/bash", "symlink/vim"}},
+		{"links  ~/.$1rc  *.symlink", []string{".bashrc", ".vimrc"}},
+		{"links  ~/.config/$1_$2  *.*", []string{".config/bash_symlink", ".config/vim_symlink"}},
+		{"links  ~/links  *.symlink", []string{"links/bash.symlink", "links/vim.symlink"}},
+		{"links  ~/.config/$0  *.symlink", []string{".config/bash.symlink", ".config/vim.symlink"}},
+	}
+
+	for _, test := range tests {
+		directory := newTestModule(t, map[string]string{
+			"dotman":             test.line,
+			"links/bash.symlink": "b",
+			"links/vim.symlink":  "v",
+		})
+
+		pathMap, err := NewPathMap(filepath.Join(directory, "dotman"))
+		if err != nil {
+			t.Errorf("NewPathMap(%q) failed: %s", test.line, err)
+			continue
+		}
+
+		targets := make([]string, 0)
+		for _, instruction := range pathMap.GetInstructions() {
+			relativePath, _ := filepath.Rel(homeDirectory, instruction.Target())
+			targets = append(targets, filepath.ToSlash(relativePath))
+		}
+
+		sort.Strings(targets)
+		if !reflect.DeepEqual(targets, test.expected) {
+			t.Errorf("%q maps to %q, expected %q", test.line, targets, test.expected)
+		}
+	}
+}
+
+func getTestHomeDirectory(t *testing.T) string {
+	homeDirectory, err := fs.GetUserHomeDirectory()
+	if err != nil {
+		t.Skipf("Unable to determine the home directory. %s", err)
+	}
+
+	return homeDirectory
+}
+
+func TestPathMapPaths(t *testing.T) {
+
+	homeDirectory := getTestHomeDirectory(t)
+	hostname, _ := os.Hostname()
+	t.Setenv("UP", "../..")
+
+	tests := []struct {
+		text   string
+		source string // relative to the module directory
+		target string // relative to the home directory (or absolute)
+		err    string // empty if no error is expected
+	}{
+		{"rc  ~/.rc", "rc", ".rc", ""},
+		{"rc  .rc", "rc", ".rc", ""},
+		{"rc  /etc/rc", "rc", "/etc/rc", ""},
+		{"set HOME /elsewhere\nrc  .rc", "rc", ".rc", ""},
+		{"dir/../rc  ~/.rc", "rc", ".rc", ""},
+		{"include  ~/.include", "include", ".include", ""},
+		{"set  ~/.set", "set", ".set", ""},
+		{"set NAME  ~/.set", "", "", "can be read as a \"set\" directive and as a path map entry"},
+		{"hosts/$HOSTNAME/rc  ~/.rc", "hosts/" + hostname + "/rc", ".rc", ""},
+		{"../shared/rc  ~/.rc", "../shared/rc", ".rc", ""},
+		{"dir/../../rc  ~/.rc", "../rc", ".rc", ""},
+		{"set NAME shared\n../$NAME/rc  ~/.rc", "../shared/rc", ".rc", ""},
+		{"$UP/rc  ~/.rc", "", "", "which is outside of the module"},
+		{"set DIR /etc\n$DIR/passwd  ~/.rc", "", "", "the variables turn it into"},
+		{"rc  ~/.$1", "", "", "the entry has no pattern"},
+		{"rc  ~/.$2  re:^(.+)rc$", "", "", "which the pattern does not have"},
+		{"rc  ~/.${2}  *rc", "", "", "which the pattern does not have"},
+		{"rc  ~/.$99999999999999999999  *rc", "", "", "which the pattern does not have"},
+	}
+
+	for _, test := range tests {
+		files := map[string]string{"dotman": test.text}
+		if test.source != "" {
+			files[test.source] = "source"
+		}
+
+		directory := newTestModule(t, files)
+
+		pathMap, err := NewPathMap(filepath.Join(directory, "dotman"))
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("NewPathMap(%q) returned the error %v, expected %q", test.text, err, test.err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("NewPathMap(%q) failed: %s", test.text, err)
+			continue
+		}
+
+		instructions := pathMap.GetInstructions()
+		if len(instructions) != 1 {
+			t.Errorf("NewPathMap(%q) has %d entries, expected 1", test.text, len(instructions))
+			continue
+		}
+
+		source, target := filepath.Join(directory, filepath.FromSlash(test.source)), filepath.FromSlash(test.target)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(homeDirectory, target)
+		}
+
+		if instructions[0].Source() != source || instructions[0].Target() != target {
+			t.Errorf("NewPathMap(%q) maps %q to %q, expected %q to %q", test.text, instructions[0].Source(), instructions[0].Target(), source, target)
+		}
+	}
+}
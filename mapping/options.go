@@ -0,0 +1,217 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"fmt"
+	"github.com/andreaskoch/dotman/util/glob"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// the option which marks entries whose source files are stored encrypted in the module
+	EncryptedOption = "encrypted"
+
+	// the option which sets the permissions of the deployed files (e.g. "mode=0600")
+	ModeOption = "mode"
+
+	// the option which deploys a symbolic link to the source instead of a copy
+	LinkOption = "link"
+
+	// the option which renders the source files as templates when they are deployed
+	TemplateOption = "template"
+
+	// the option which ignores entries whose source does not exist
+	OptionalOption = "optional"
+
+	// the option which deploys the entry only if the target does not exist yet
+	OnceOption = "once"
+
+	// the option which excludes files from directory entries (e.g. "exclude=*.log,cache")
+	ExcludeOption = "exclude"
+
+	// the prefix of columns which exclude a single pattern (e.g. "!*.swp")
+	ExcludePrefix = "!"
+)
+
+var (
+	knownOptions = []string{EncryptedOption, ExcludeOption, LinkOption, ModeOption, OnceOption, OptionalOption, TemplateOption}
+)
+
+// Options contains the settings of a path map entry.
+type Options struct {
+	Encrypted bool
+	Link      bool
+	Template  bool
+	Optional  bool
+	Once      bool
+
+	// the permissions of the deployed files (if HasMode is set)
+	Mode    os.FileMode
+	HasMode bool
+
+	// the patterns of the files which are not copied from or to directories
+	Exclude []string
+
+	excludeGlobs []*glob.Glob
+}
+
+func newOptions() *Options {
+	return &Options{
+		Exclude:      make([]string, 0),
+		excludeGlobs: make([]*glob.Glob, 0),
+	}
+}
+
+// isOption checks if the supplied column after the target path is an option rather than
+// a pattern: a known option (with or without a value) or an exclude pattern. Other columns
+// (e.g. misspelled options) are patterns; patterns which look like an option are written
+// with the prefix "glob:".
+func isOption(column string) bool {
+	if strings.HasPrefix(column, ExcludePrefix) {
+		return true
+	}
+
+	name := column
+	if index := strings.Index(column, "="); index != -1 {
+		name = column[:index]
+	}
+
+	for _, knownOption := range knownOptions {
+		if name == knownOption {
+			return true
+		}
+	}
+
+	return false
+}
+
+// set assigns the supplied option (e.g. "once", "mode=0600" or "!*.swp").
+func (options *Options) set(option string) error {
+
+	if strings.HasPrefix(option, ExcludePrefix) {
+		return options.addExcludePattern(strings.TrimPrefix(option, ExcludePrefix))
+	}
+
+	name, value, hasValue := option, "", false
+	if index := strings.Index(option, "="); index != -1 {
+		name, value, hasValue = option[:index], option[index+1:], true
+	}
+
+	switch name {
+
+	case ModeOption:
+		if !hasValue || value == "" {
+			return fmt.Errorf("The option %q requires a value (e.g. \"%s=0600\").", name, name)
+		}
+
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil || mode > 0777 {
+			return fmt.Errorf("%q is not a valid file mode. Please use an octal number between 0000 and 0777.", value)
+		}
+
+		options.Mode, options.HasMode = os.FileMode(mode), true
+		return nil
+
+	case ExcludeOption:
+		if !hasValue || value == "" {
+			return fmt.Errorf("The option %q requires a value (e.g. \"%s=*.log\").", name, name)
+		}
+
+		for _, pattern := range strings.Split(value, ",") {
+			if err := options.addExcludePattern(pattern); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	}
+
+	var flag *bool
+	switch name {
+
+	case EncryptedOption:
+		flag = &options.Encrypted
+
+	case LinkOption:
+		flag = &options.Link
+
+	case TemplateOption:
+		flag = &options.Template
+
+	case OptionalOption:
+		flag = &options.Optional
+
+	case OnceOption:
+		flag = &options.Once
+
+	default:
+		return &ParseError{
+			Message: fmt.Sprintf("%q is not a known option.", option),
+			Hint:    fmt.Sprintf("The known options are %s.", strings.Join(knownOptions, ", ")),
+		}
+
+	}
+
+	if hasValue {
+		return fmt.Errorf("The option %q does not take a value.", name)
+	}
+
+	*flag = true
+	return nil
+}
+
+// validate checks that the options can be combined.
+func (options *Options) validate() error {
+	if options.Link && (options.Encrypted || options.Template || options.HasMode) {
+		return fmt.Errorf("The option %q cannot be combined with the options %q, %q or %q.", LinkOption, EncryptedOption, TemplateOption, ModeOption)
+	}
+
+	return nil
+}
+
+func (options *Options) addExcludePattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("The exclude pattern must not be empty.")
+	}
+
+	excludeGlob, err := glob.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	options.Exclude = append(options.Exclude, pattern)
+	options.excludeGlobs = append(options.excludeGlobs, excludeGlob)
+	return nil
+}
+
+// IsExcluded checks if the supplied path (relative to the source or target of an entry)
+// matches one of the exclude patterns. Patterns with a slash (e.g. "doc/tags" or "**/.netrwhist")
+// are matched against the path and the paths of its parent directories, all other patterns
+// against the name of every directory and file in it.
+func (options *Options) IsExcluded(relativePath string) bool {
+
+	names := strings.Split(filepath.ToSlash(relativePath), "/")
+	for index, excludeGlob := range options.excludeGlobs {
+		hasSlash := strings.Contains(options.Exclude[index], "/")
+
+		for end := 1; end <= len(names); end++ {
+			path := names[end-1]
+			if hasSlash {
+				path = strings.Join(names[:end], "/")
+			}
+
+			if excludeGlob.Match(path) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
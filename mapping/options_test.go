@@ -0,0 +1,106 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"testing"
+)
+
+func TestOptionsIsExcluded(t *testing.T) {
+
+	tests := []struct {
+		options  []string
+		path     string
+		excluded bool
+	}{
+		{[]string{}, "file", false},
+		{[]string{"!*.swp"}, "file.swp", true},
+		{[]string{"!*.swp"}, "dir/file.swp", true},
+		{[]string{"!*.swp"}, "file.swp.txt", false},
+		{[]string{"!cache"}, "cache", true},
+		{[]string{"!cache"}, "cache/entry", true},
+		{[]string{"!cache"}, "dir/cache/entry", true},
+		{[]string{"!cache"}, "cached", false},
+		{[]string{"exclude=*.log,.netrwhist"}, "a/.netrwhist", true},
+		{[]string{"exclude=*.log,.netrwhist"}, "a/b.log", true},
+		{[]string{"exclude=*.log,.netrwhist"}, "a/b.txt", false},
+		{[]string{"exclude=*.log", "!*.swp"}, "x.swp", true},
+		{[]string{"!doc/tags"}, "doc/tags", true},
+		{[]string{"!doc/tags"}, "doc/tags/file", true},
+		{[]string{"!doc/tags"}, "bundle/doc/tags", false},
+		{[]string{"!doc/tags"}, "tags", false},
+		{[]string{"!**/.netrwhist"}, ".netrwhist", true},
+		{[]string{"!**/.netrwhist"}, "a/b/.netrwhist", true},
+		{[]string{"!bundle/*/doc"}, "bundle/fugitive/doc/tags", true},
+		{[]string{"!bundle/*/doc"}, "bundle/doc", false},
+		{[]string{"!{a,b}"}, "{a,b}", true},
+	}
+
+	for _, test := range tests {
+		options := newOptions()
+		for _, option := range test.options {
+			if err := options.set(option); err != nil {
+				t.Fatalf("set(%q) failed: %s", option, err)
+			}
+		}
+
+		if excluded := options.IsExcluded(test.path); excluded != test.excluded {
+			t.Errorf("%q excludes %q: %v, expected %v", test.options, test.path, excluded, test.excluded)
+		}
+	}
+}
+
+func TestOptionsSetInvalid(t *testing.T) {
+	for _, option := range []string{"!", "exclude=", "!doc/[", "mode=abc", "mode=0600=1", "link=true", "bogus=1"} {
+		if err := newOptions().set(option); err == nil {
+			t.Errorf("set(%q) succeeded, expected an error", option)
+		}
+	}
+}
+
+func TestIsOption(t *testing.T) {
+
+	tests := []struct {
+		column   string
+		isOption bool
+	}{
+		{"link", true},
+		{"mode=0600", true},
+		{"exclude=*.log", true},
+		{"!*.swp", true},
+		{"*.conf", false},
+		{"foo=bar", false},
+		{"links", false},
+		{"glob:link", false},
+	}
+
+	for _, test := range tests {
+		if isOption := isOption(test.column); isOption != test.isOption {
+			t.Errorf("isOption(%q) = %v, expected %v", test.column, isOption, test.isOption)
+		}
+	}
+}
+
+func TestOptionsSetMode(t *testing.T) {
+
+	options := newOptions()
+	if options.HasMode {
+		t.Errorf("newOptions() has a mode, expected none")
+	}
+
+	if err := options.set("mode=0000"); err != nil {
+		t.Fatalf("set(%q) failed: %s", "mode=0000", err)
+	}
+
+	if !options.HasMode || options.Mode != 0 {
+		t.Errorf("set(%q) = %v, %04o, expected the mode 0000", "mode=0000", options.HasMode, options.Mode)
+	}
+
+	// the mode 0000 cannot be combined with links either
+	options.Link = true
+	if err := options.validate(); err == nil {
+		t.Errorf("validate() succeeded for a link with the mode 0000, expected an error")
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"fmt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// the directive which includes the entries of another file
+	IncludeDirective = "include"
+
+	// the directive which declares a variable
+	SetDirective = "set"
+)
+
+var (
+	// the name of a variable
+	variableNamePattern = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+)
+
+// A parser reads the entries of a dotman file (or dotman.json manifest) and all files it includes.
+// The source paths of all entries are relative to the module directory. Statements which cannot
+// be used are skipped and their problems collected.
+type parser struct {
+	directory string
+	entries   []*pathMapEntry
+	variables variables
+	errors    ParseErrors
+	warnings  ParseErrors
+
+	// the files which are currently being parsed (the last one includes no other file yet)
+	includeStack []string
+}
+
+func newParser(directory string) *parser {
+	return &parser{
+		directory:    directory,
+		entries:      make([]*pathMapEntry, 0),
+		variables:    newVariables(),
+		errors:       make(ParseErrors, 0),
+		warnings:     make(ParseErrors, 0),
+		includeStack: make([]string, 0),
+	}
+}
+
+func (parser *parser) parseFile(path string) error {
+
+	statements, err := ReadStatements(path)
+	if parseError, isParseError := err.(*ParseError); isParseError {
+		parser.errors = append(parser.errors, parseError)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	parser.includeStack = append(parser.includeStack, path)
+	defer func() {
+		parser.includeStack = parser.includeStack[:len(parser.includeStack)-1]
+	}()
+
+	for _, statement := range statements {
+
+		if statement.err != nil {
+			parser.errors = append(parser.errors, statement.newError(statement.err))
+			continue
+		}
+
+		switch {
+
+		// include the entries of another file
+		case statement.IsInclude():
+			includePath, err := parser.getIncludePath(statement.Include)
+			if err != nil {
+				parser.errors = append(parser.errors, statement.newError(errorAt(err, statement.Include)))
+				continue
+			}
+
+			// "include <path>" might be meant as an entry for a file named "include"
+			if !IsManifest(statement.file) && fs.PathExists(filepath.Join(parser.directory, IncludeDirective)) {
+				parser.warnings = append(parser.warnings, statement.newError(&ParseError{
+					Message: fmt.Sprintf("The module contains a file named %q but the line is read as the %q directive.", IncludeDirective, IncludeDirective),
+					Hint:    fmt.Sprintf("To map the file, separate the columns with two spaces (\"%s  %s\").", IncludeDirective, statement.Include),
+					token:   IncludeDirective,
+				}))
+			}
+
+			if err := parser.parseFile(includePath); err != nil {
+				return err
+			}
+
+		// declare a variable for the following statements
+		case statement.IsSet():
+			value, err := expandPathVariables(statement.Value, parser.variables)
+			if err != nil {
+				parser.errors = append(parser.errors, statement.newError(err))
+				continue
+			}
+
+			parser.variables.set(statement.Set, value)
+
+		// map the files which follow the naming convention
+		case statement.IsAuto():
+			autoEntry, err := newAutoEntry(parser.directory, statement, parser.variables)
+			if err != nil {
+				parser.errors = append(parser.errors, statement.newError(err))
+				continue
+			}
+
+			autoEntry.position = formatPosition(statement.file, statement.line, statement.entry, 0)
+			parser.addEntry(statement, autoEntry)
+
+		// create a path map entry from the statement
+		case statement.IsEntry():
+			pathMapEntry, err := newPathMapEntry(parser.directory, statement, parser.variables)
+			if err != nil {
+				parser.errors = append(parser.errors, statement.newError(err))
+				continue
+			}
+
+			// append the path map entry to the list
+			pathMapEntry.position = formatPosition(statement.file, statement.line, statement.entry, 0)
+			parser.addEntry(statement, pathMapEntry)
+
+		}
+	}
+
+	return nil
+}
+
+// addEntry appends the supplied entry to the list and collects its warnings.
+func (parser *parser) addEntry(statement *Statement, entry *pathMapEntry) {
+	for _, warning := range entry.warnings {
+		parser.warnings = append(parser.warnings, statement.newError(warning))
+	}
+
+	parser.entries = append(parser.entries, entry)
+}
+
+// getIncludePath returns the path of the supplied included file (relative to the file
+// which is currently being parsed) if it exists and doesn't include itself.
+func (parser *parser) getIncludePath(includePath string) (string, error) {
+
+	includePath, err := expandPathVariables(normalizePathSpecification(includePath), parser.variables)
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(includePath) {
+		currentFile := parser.includeStack[len(parser.includeStack)-1]
+		includePath = filepath.Join(filepath.Dir(currentFile), includePath)
+	}
+
+	if !fs.IsFile(includePath) {
+		return "", &ParseError{
+			Message: fmt.Sprintf("The included file %q does not exist.", includePath),
+			Hint:    "The path is relative to the file which includes it.",
+		}
+	}
+
+	for index, includingFile := range parser.includeStack {
+		if includingFile == includePath {
+			cycle := append(parser.includeStack[index:], includePath)
+			return "", fmt.Errorf("The file %q includes itself (%s).", includePath, strings.Join(cycle, " → "))
+		}
+	}
+
+	return includePath, nil
+}
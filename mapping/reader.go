@@ -0,0 +1,229 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// the extension of files which use the JSON format instead of the text format
+	ManifestExtension = ".json"
+)
+
+var (
+	// an "auto" directive with a target directory which is separated by a single space
+	autoDirectivePattern = regexp.MustCompile(`^` + AutoDirective + ` [^ \t]`)
+
+	// an "include" directive with a path which is separated by a single space
+	includeDirectivePattern = regexp.MustCompile(`^` + IncludeDirective + ` [^ \t]`)
+
+	// a "set" directive whose name and value are separated by single spaces
+	setDirectivePattern = regexp.MustCompile(`^` + SetDirective + ` ([^ \t]+) ([^ \t].*)$`)
+)
+
+// IsManifest checks if the supplied file uses the JSON format.
+func IsManifest(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ManifestExtension)
+}
+
+// ReadStatements reads the statements of the supplied dotman file or dotman.json
+// manifest (empty lines included, module metadata excluded). Statements which cannot be read carry an error which is reported when
+// the file is parsed; files which cannot be read at all return an error (a ParseError
+// if the manifest is not valid JSON).
+func ReadStatements(path string) ([]*Statement, error) {
+	if IsManifest(path) {
+		return readJSONStatements(path)
+	}
+
+	return readTextStatements(path, false)
+}
+
+// FormatFile returns the content of the supplied dotman file in its canonical format
+// (see FormatText). Module metadata is kept as it is.
+func FormatFile(path string) (string, error) {
+	statements, err := readTextStatements(path, true)
+	if err != nil {
+		return "", err
+	}
+
+	return FormatText(statements)
+}
+
+// readTextStatements reads the statements of the supplied dotman file (including the empty lines).
+// If keepLayout is set, module metadata is returned as statements which are written as they are.
+func readTextStatements(path string, keepLayout bool) ([]*Statement, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	statements := make([]*Statement, 0)
+	for lineNumber, line := range fs.GetLines(file) {
+
+		// empty lines are kept so the layout of the file can be restored
+		if isEmptyLine(line) {
+			statements = append(statements, &Statement{file: path, line: lineNumber + 1, isEmptyLine: true})
+			continue
+		}
+
+		// module metadata
+		if isMetadata(line) {
+			if keepLayout {
+				statements = append(statements, &Statement{text: strings.TrimSpace(line), isVerbatim: true})
+			}
+
+			continue
+		}
+
+		statement := readTextStatement(strings.TrimSpace(line))
+		statement.file = path
+		statement.line = lineNumber + 1
+		statement.text = line
+
+		// the columns of the tokenizer don't include the indentation
+		if parseError, isParseError := statement.err.(*ParseError); isParseError && parseError.Column > 0 {
+			parseError.Column += len([]rune(line)) - len([]rune(strings.TrimLeft(line, " \t")))
+		}
+
+		statements = append(statements, statement)
+	}
+
+	return statements, nil
+}
+
+// readTextStatement creates a statement from the supplied (non-empty) line of a dotman file.
+func readTextStatement(line string) *Statement {
+
+	if isComment(line) {
+		return &Statement{Comment: strings.TrimPrefix(line, "#")}
+	}
+
+	words, err := splitColumns(line, true)
+	if err != nil {
+		return &Statement{err: err}
+	}
+
+	// include the entries of another file ("include <path>"). The path is separated by a
+	// single space, so "include  <target>" maps a file named "include".
+	if len(words) == 2 && includeDirectivePattern.MatchString(line) {
+		return &Statement{Include: words[1]}
+	}
+
+	// map the files which follow the naming convention ("auto [<target directory>]"). The target
+	// directory is separated by a single space, so "auto  <target>" maps a file named "auto".
+	if len(words) == 1 && line == AutoDirective {
+		return &Statement{Auto: DefaultAutoTarget}
+	}
+
+	if len(words) == 2 && autoDirectivePattern.MatchString(line) {
+		return &Statement{Auto: words[1]}
+	}
+
+	// declare a variable for the following lines ("set <name> <value>"). The words are separated
+	// by single spaces, so "set  <target>" maps a file named "set". The value is the rest of the line.
+	if submatches := setDirectivePattern.FindStringSubmatch(line); submatches != nil && variableNamePattern.MatchString(submatches[1]) {
+		return &Statement{Set: submatches[1], Value: readSetValue(submatches[2])}
+	}
+
+	// "set <name>  <value>" could be a directive as well as an entry for the source "set <name>"
+	if len(words) > 2 && words[0] == SetDirective && variableNamePattern.MatchString(words[1]) && strings.HasPrefix(line, SetDirective+" "+words[1]) {
+		return &Statement{err: &ParseError{
+			Message: fmt.Sprintf("%q can be read as a %q directive and as a path map entry.", line, SetDirective),
+			Hint:    fmt.Sprintf("Separate the words of the directive with single spaces (\"%s %s <value>\") or write the source of an entry as \"./%s %s\".", SetDirective, words[1], SetDirective, words[1]),
+		}}
+	}
+
+	// path map entry ("<source>  <target>  [<pattern>]  [<options>...]")
+	columns, err := splitColumns(line, false)
+	if err != nil {
+		return &Statement{err: err}
+	}
+
+	if len(columns) < 2 {
+		return &Statement{err: &ParseError{
+			Message: fmt.Sprintf("%q is not a valid path map entry. A path map entry should conists if a source and target path and optionally a pattern, all separated by some whitespace.", line),
+			Hint:    "Separate the columns with at least two spaces or a tab.",
+		}}
+	}
+
+	statement := &Statement{
+		Source: columns[0],
+		Target: columns[1],
+	}
+
+	// the pattern (only in the column after the target path) and options
+	for index, column := range columns[2:] {
+		column = strings.TrimSpace(column)
+
+		if index > 0 || isOption(column) {
+			statement.addOption(column)
+			continue
+		}
+
+		statement.Pattern = column
+	}
+
+	return statement
+}
+
+// readSetValue returns the value of a "set" directive: the rest of the line as it is or,
+// if it is a single quoted word, its content (the quotes of the line have been checked before).
+func readSetValue(text string) string {
+	if !strings.HasPrefix(text, `"`) && !strings.HasPrefix(text, "'") {
+		return text
+	}
+
+	if words, err := splitColumns(text, true); err == nil && len(words) == 1 {
+		return words[0]
+	}
+
+	return text
+}
+
+func readJSONStatements(path string) ([]*Statement, error) {
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest struct {
+		Entries []*Statement `json:"entries"`
+	}
+
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, NewJSONError(path, content, err)
+	}
+
+	statements := make([]*Statement, 0, len(manifest.Entries))
+	for index, statement := range manifest.Entries {
+
+		// null entries are empty lines
+		if statement == nil {
+			statement = &Statement{isEmptyLine: true}
+		}
+
+		statement.file = path
+		statement.entry = index + 1
+		if err := statement.validate(); err != nil {
+			statement.err = err
+		}
+
+		statements = append(statements, statement)
+	}
+
+	return statements, nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Statement is a single line of a dotman file or a single entry of a dotman.json
+// manifest: a comment, an include or auto directive, a variable declaration or a path map entry.
+type Statement struct {
+	Comment string `json:"comment,omitempty"`
+
+	// include <path>
+	Include string `json:"include,omitempty"`
+
+	// set <name> <value>
+	Set   string `json:"set,omitempty"`
+	Value string `json:"value,omitempty"`
+
+	// auto [<target directory>]
+	Auto string `json:"auto,omitempty"`
+
+	// <source>  <target>  [<pattern>]  [<options>...]
+	Source  string                 `json:"source,omitempty"`
+	Target  string                 `json:"target,omitempty"`
+	Pattern string                 `json:"pattern,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
+
+	// the file and line (or manifest entry) the statement was read from
+	file  string
+	line  int
+	entry int
+
+	// the line of the dotman file
+	text string
+
+	// empty lines separate the blocks of a dotman file (null entries in a dotman.json manifest)
+	isEmptyLine bool
+
+	// module metadata which is written as it is
+	isVerbatim bool
+
+	// the error which occured while the statement was read
+	err error
+}
+
+// newError returns the supplied problem of the statement as a parse error.
+func (statement *Statement) newError(err error) *ParseError {
+	parseError := errorAt(err, "")
+	parseError.File = statement.file
+	parseError.Line = statement.line
+	parseError.Entry = statement.entry
+
+	if parseError.Column == 0 && parseError.token != "" {
+		if index := strings.Index(statement.text, parseError.token); index != -1 {
+			parseError.Column = len([]rune(statement.text[:index])) + 1
+		}
+	}
+
+	return parseError
+}
+
+func (statement *Statement) IsInclude() bool {
+	return statement.Include != ""
+}
+
+func (statement *Statement) IsSet() bool {
+	return statement.Set != ""
+}
+
+func (statement *Statement) IsAuto() bool {
+	return statement.Auto != ""
+}
+
+func (statement *Statement) IsEntry() bool {
+	return statement.Source != "" || statement.Target != ""
+}
+
+// IsEmptyLine checks if the statement is an empty line.
+func (statement *Statement) IsEmptyLine() bool {
+	return statement.isEmptyLine
+}
+
+// IsComment checks if the statement is nothing but a comment.
+func (statement *Statement) IsComment() bool {
+	return !statement.isEmptyLine && !statement.isVerbatim && !statement.IsInclude() && !statement.IsSet() && !statement.IsAuto() && !statement.IsEntry()
+}
+
+// validate checks that the statement is exactly one kind of statement and complete.
+func (statement *Statement) validate() error {
+	kinds := 0
+	for _, isKind := range []bool{statement.IsInclude(), statement.IsSet(), statement.IsAuto(), statement.IsEntry()} {
+		if isKind {
+			kinds++
+		}
+	}
+
+	if kinds > 1 {
+		return fmt.Errorf("A statement can either include a file, set a variable, map the files automatically or map a source to a target path but not several of them.")
+	}
+
+	if statement.IsSet() && !variableNamePattern.MatchString(statement.Set) {
+		return fmt.Errorf("%q is not a valid variable name.", statement.Set)
+	}
+
+	if statement.IsEntry() && (statement.Source == "" || statement.Target == "") {
+		return fmt.Errorf("A path map entry needs a source and a target path.")
+	}
+
+	return nil
+}
+
+// addOption adds an option column of a dotman file (e.g. "link" or "mode=0600").
+func (statement *Statement) addOption(column string) {
+	if statement.Options == nil {
+		statement.Options = make(map[string]interface{})
+	}
+
+	// a single exclude pattern (e.g. "!*.swp")
+	if strings.HasPrefix(column, ExcludePrefix) {
+		patterns, _ := statement.Options[ExcludeOption].([]string)
+		statement.Options[ExcludeOption] = append(patterns, strings.TrimPrefix(column, ExcludePrefix))
+		return
+	}
+
+	index := strings.Index(column, "=")
+	if index == -1 {
+		statement.Options[column] = true
+		return
+	}
+
+	name, value := column[:index], column[index+1:]
+
+	// exclude patterns are a list which can be spread over several columns
+	if name == ExcludeOption {
+		patterns, _ := statement.Options[name].([]string)
+		statement.Options[name] = append(patterns, strings.Split(value, ",")...)
+		return
+	}
+
+	statement.Options[name] = value
+}
+
+// getOptionColumns returns the options of the statement in the form of the
+// option columns of a dotman file, sorted by their name.
+func (statement *Statement) getOptionColumns() ([]string, error) {
+
+	names := make([]string, 0, len(statement.Options))
+	for name := range statement.Options {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	columns := make([]string, 0, len(names))
+	for _, name := range names {
+
+		switch value := statement.Options[name].(type) {
+
+		case bool:
+			if value {
+				columns = append(columns, name)
+			}
+
+		case string:
+			columns = append(columns, name+"="+value)
+
+		case float64:
+			columns = append(columns, fmt.Sprintf("%s=%v", name, value))
+
+		case []string:
+			columns = append(columns, getListOptionColumns(name, value)...)
+
+		case []interface{}:
+			values := make([]string, 0, len(value))
+			for _, listValue := range value {
+				text, isText := listValue.(string)
+				if !isText {
+					return nil, fmt.Errorf("The option %q can only contain text values.", name)
+				}
+
+				values = append(values, text)
+			}
+
+			columns = append(columns, getListOptionColumns(name, values)...)
+
+		default:
+			return nil, fmt.Errorf("The option %q has an invalid value.", name)
+
+		}
+	}
+
+	return columns, nil
+}
+
+// getListOptionColumns returns the option columns for the supplied list of values
+// (e.g. "exclude=*.log,cache"). Exclude patterns which contain a comma get a column
+// of their own (e.g. "!{a,b}").
+func getListOptionColumns(name string, values []string) []string {
+	if name != ExcludeOption {
+		return []string{name + "=" + strings.Join(values, ",")}
+	}
+
+	columns := make([]string, 0)
+	patterns := make([]string, 0, len(values))
+	for _, pattern := range values {
+		if strings.Contains(pattern, ",") {
+			columns = append(columns, ExcludePrefix+pattern)
+			continue
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	if len(patterns) > 0 {
+		columns = append([]string{name + "=" + strings.Join(patterns, ",")}, columns...)
+	}
+
+	return columns
+}
@@ -0,0 +1,118 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"strings"
+)
+
+// splitColumns splits a line of a dotman file into its columns. Columns are separated
+// by two or more spaces or by tabs (or by any white space if singleSpaceSeparates is set).
+//
+// A column which starts with a double or single quote ends with the same quote and can
+// contain any character. Inside double quotes \" and \\ are escaped characters. Outside
+// of quotes a backslash escapes a space, a tab, a quote or "#"; all other backslashes
+// (e.g. in regular expressions) are kept as they are.
+func splitColumns(line string, singleSpaceSeparates bool) ([]string, error) {
+
+	characters := []rune(line)
+	columns := make([]string, 0)
+
+	var column []rune
+	isInColumn := false
+	pendingSpaces := 0
+
+	var quote rune
+	quoteStart := 0
+
+	endColumn := func() {
+		if isInColumn {
+			columns = append(columns, string(column))
+		}
+
+		column = nil
+		isInColumn = false
+		pendingSpaces = 0
+	}
+
+	add := func(character rune) {
+		for ; pendingSpaces > 0; pendingSpaces-- {
+			column = append(column, ' ')
+		}
+
+		column = append(column, character)
+		isInColumn = true
+	}
+
+	for index := 0; index < len(characters); index++ {
+		character := characters[index]
+
+		next := rune(0)
+		if index+1 < len(characters) {
+			next = characters[index+1]
+		}
+
+		// quoted text
+		if quote != 0 {
+			switch {
+
+			case character == quote:
+				quote = 0
+
+			case quote == '"' && character == '\\' && (next == '"' || next == '\\'):
+				add(next)
+				index++
+
+			default:
+				add(character)
+
+			}
+
+			continue
+		}
+
+		switch {
+
+		// column separators
+		case character == '\t' || (character == ' ' && (singleSpaceSeparates || next == ' ' || next == '\t')):
+			endColumn()
+			for index+1 < len(characters) && (characters[index+1] == ' ' || characters[index+1] == '\t') {
+				index++
+			}
+
+		// single spaces are part of the column unless they are at its end
+		case character == ' ':
+			if isInColumn {
+				pendingSpaces++
+			}
+
+		// quotes at the beginning of a column
+		case (character == '"' || character == '\'') && !isInColumn:
+			quote = character
+			quoteStart = index
+			isInColumn = true
+
+		// escaped characters
+		case character == '\\' && strings.ContainsRune(" \t\"'#", next) && next != 0:
+			add(next)
+			index++
+
+		default:
+			add(character)
+
+		}
+	}
+
+	if quote != 0 {
+		return nil, &ParseError{
+			Column:  quoteStart + 1,
+			Message: "The quote is not closed.",
+			Hint:    "Close the quote or escape it with a backslash.",
+		}
+	}
+
+	endColumn()
+	return columns, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitColumns(t *testing.T) {
+
+	tests := []struct {
+		line                 string
+		singleSpaceSeparates bool
+		expected             []string
+	}{
+		{"", false, []string{}},
+		{"   ", false, []string{}},
+		{"vimrc  ~/.vimrc", false, []string{"vimrc", "~/.vimrc"}},
+		{"vimrc\t~/.vimrc", false, []string{"vimrc", "~/.vimrc"}},
+		{"  vimrc \t  ~/.vimrc  ", false, []string{"vimrc", "~/.vimrc"}},
+		{"My Documents  ~/My Documents", false, []string{"My Documents", "~/My Documents"}},
+		{"a b c  d", true, []string{"a", "b", "c", "d"}},
+		{"set  NAME  value", false, []string{"set", "NAME", "value"}},
+
+		// quotes
+		{`"a  b"  '~/c  d'`, false, []string{"a  b", "~/c  d"}},
+		{`"a \"b\" \\c"  target`, false, []string{`a "b" \c`, "target"}},
+		{`'a \'  target`, false, []string{`a \`, "target"}},
+		{`""  target`, false, []string{"", "target"}},
+		{`"a b"c  target`, false, []string{"a bc", "target"}},
+		{`a"b  target`, false, []string{`a"b`, "target"}},
+
+		// escapes
+		{`a\ \ b  target`, false, []string{"a  b", "target"}},
+		{`\#notes  ~/notes`, false, []string{"#notes", "~/notes"}},
+		{`\"quoted\"  target`, false, []string{`"quoted"`, "target"}},
+		{`dir  ~/dir  re:^\w+\.conf$`, false, []string{"dir", "~/dir", `re:^\w+\.conf$`}},
+		{`trailing\`, false, []string{`trailing\`}},
+
+		// unicode
+		{"ünïcödé  ~/ü", false, []string{"ünïcödé", "~/ü"}},
+	}
+
+	for _, test := range tests {
+		columns, err := splitColumns(test.line, test.singleSpaceSeparates)
+		if err != nil {
+			t.Errorf("splitColumns(%q) failed: %s", test.line, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(columns, test.expected) {
+			t.Errorf("splitColumns(%q, %v) = %q, expected %q", test.line, test.singleSpaceSeparates, columns, test.expected)
+		}
+	}
+}
+
+func TestSplitColumnsUnclosedQuotes(t *testing.T) {
+
+	tests := []struct {
+		line           string
+		expectedColumn int
+	}{
+		{`"vimrc  ~/.vimrc`, 1},
+		{`vimrc  '~/.vimrc`, 8},
+		{`ü  "a \"`, 4},
+	}
+
+	for _, test := range tests {
+		columns, err := splitColumns(test.line, false)
+		parseError, isParseError := err.(*ParseError)
+		if !isParseError {
+			t.Errorf("splitColumns(%q) = %q, %v, expected a parse error", test.line, columns, err)
+			continue
+		}
+
+		if parseError.Column != test.expectedColumn {
+			t.Errorf("splitColumns(%q) reports column %d, expected %d", test.line, parseError.Column, test.expectedColumn)
+		}
+	}
+}
@@ -5,8 +5,10 @@
 package mapping
 
 import (
+	"fmt"
 	"github.com/andreaskoch/dotman/util/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
@@ -15,17 +17,10 @@ var (
 	DirectorySeparatorPattern = regexp.MustCompile(`[\/]{1,}`)
 
 	HomeDirectoryBashPattern = regexp.MustCompile(`^~`)
-
-	UnixEnvironmentVariablePattern = regexp.MustCompile(`\$(\w+)`)
-
-	WindowsEnvironmentVariablePattern = regexp.MustCompile(`%(\w+)%`)
 )
 
 func normalizePathSpecification(path string) string {
 
-	// trim leading and trailing white space
-	path = strings.TrimSpace(path)
-
 	// replace all directory separators with the ones for the current platform
 	path = DirectorySeparatorPattern.ReplaceAllString(path, string(os.PathSeparator))
 
@@ -35,36 +30,89 @@ func normalizePathSpecification(path string) string {
 	return path
 }
 
-func expandPathVariables(path string) string {
+func expandPathVariables(path string, variables variables) (string, error) {
+
+	// replace the variables
+	path, err := variables.expand(path)
+	if err != nil {
+		return "", err
+	}
 
 	// replace ~/ with the real home directory path
-	if homeDirectory, err := fs.GetUserHomeDirectory(); err == nil {
+	if HomeDirectoryBashPattern.MatchString(path) {
+		homeDirectory, err := getHomeDirectory()
+		if err != nil {
+			return "", err
+		}
+
 		path = HomeDirectoryBashPattern.ReplaceAllString(path, homeDirectory)
 	}
 
-	// replace environment variables
-	path = replaceEnvironmentVariables(path, UnixEnvironmentVariablePattern)
-	path = replaceEnvironmentVariables(path, WindowsEnvironmentVariablePattern)
+	return path, nil
+}
 
-	return path
+// expandTargetPath replaces the variables and "~" in the supplied target path.
+// Relative targets are relative to the home directory of the user (the same
+// directory as "~"), no matter from which directory dotman is started.
+func expandTargetPath(path string, variables variables) (string, error) {
+
+	path, err := expandPathVariables(path, variables)
+	if err != nil {
+		return "", err
+	}
+
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	homeDirectory, err := getHomeDirectory()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDirectory, path), nil
 }
 
-func replaceEnvironmentVariables(path string, environmentVariablePattern *regexp.Regexp) string {
+// getHomeDirectory returns the home directory of the current user.
+func getHomeDirectory() (string, error) {
+	homeDirectory, err := fs.GetUserHomeDirectory()
+	if err != nil {
+		return "", fmt.Errorf("Unable to determine the home directory. %s", err)
+	}
 
-	matches := environmentVariablePattern.FindAllStringSubmatch(path, -1)
-	for _, submatch := range matches {
-		if len(submatch) < 2 {
-			continue
+	return homeDirectory, nil
+}
+
+// getTargetWarning returns a warning if the supplied target path is relative to the home directory
+// but looks as if it were relative to the current directory ("./foo", "../foo") or only becomes
+// relative because of the value of a variable ("$CONFIG/foo" with CONFIG="config").
+func getTargetWarning(path string, variables variables) *ParseError {
+
+	expandedPath, err := expandPathVariables(path, variables)
+	if err != nil || filepath.IsAbs(expandedPath) {
+		return nil
+	}
+
+	firstName := strings.SplitN(path, string(os.PathSeparator), 2)[0]
+	switch {
+
+	case firstName == "." || firstName == "..":
+		return &ParseError{
+			Message: fmt.Sprintf("The target %q is relative to your home directory, not to the current directory.", path),
+			Hint:    "Start it with \"~/\" to make this clear.",
+			token:   path,
 		}
 
-		fullmatch := submatch[0]
-		variableName := strings.TrimSpace(submatch[1])
-		value := os.Getenv(variableName)
+	case expandedPath != path && (strings.HasPrefix(path, "$") || strings.HasPrefix(path, "%")):
+		return &ParseError{
+			Message: fmt.Sprintf("The target %q becomes the relative path %q which is relative to your home directory.", path, expandedPath),
+			Hint:    "Use an absolute path in the variable or start the target with \"~/\".",
+			token:   path,
+		}
 
-		path = strings.Replace(path, fullmatch, value, 1)
 	}
 
-	return path
+	return nil
 }
 
 func isEmptyLine(line string) bool {
@@ -74,3 +122,8 @@ func isEmptyLine(line string) bool {
 func isComment(line string) bool {
 	return strings.HasPrefix(strings.TrimSpace(line), "#")
 }
+
+// isMetadata checks if the supplied line is a module metadata line (e.g. "@description My module").
+func isMetadata(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "@")
+}
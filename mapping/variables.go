@@ -0,0 +1,197 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"fmt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var (
+	// the name at the beginning of $NAME, ${NAME} and ${NAME:-default} (names starting with a digit are reserved)
+	unixVariableNamePattern = regexp.MustCompile(`^[A-Za-z_]\w*`)
+
+	// %NAME%
+	WindowsVariablePattern = regexp.MustCompile(`%([A-Za-z_]\w*)%`)
+
+	builtInVariableNames = []string{"HOME", "XDG_CONFIG_HOME", "HOSTNAME", "OS"}
+)
+
+// variables contains the variables which are declared with "set" in a dotman file.
+type variables map[string]string
+
+func newVariables() variables {
+	return make(variables)
+}
+
+func (variables variables) set(name, value string) {
+	variables[name] = value
+}
+
+// lookup returns the value of the variable with the supplied name. Variables declared
+// in the dotman file come first, then environment variables and then the built-in variables.
+// Empty values count as undefined.
+func (variables variables) lookup(name string) (string, bool) {
+
+	if value := variables[name]; value != "" {
+		return value, true
+	}
+
+	if value := os.Getenv(name); value != "" {
+		return value, true
+	}
+
+	return getBuiltInVariable(name)
+}
+
+// expand replaces all variables in the supplied text with their values.
+// Variables which are not defined and have no default value are an error.
+func (variables variables) expand(text string) (string, error) {
+
+	var err error
+	replaceVariable := func(match, name string, defaultValue string, hasDefaultValue bool) string {
+		if value, exists := variables.lookup(name); exists {
+			return value
+		}
+
+		if hasDefaultValue {
+			value, expandError := variables.expand(defaultValue)
+			if expandError != nil && err == nil {
+				err = expandError
+			}
+
+			return value
+		}
+
+		if err == nil {
+			err = &ParseError{
+				Message: fmt.Sprintf("The variable %q is not defined.", name),
+				Hint:    fmt.Sprintf("Declare it with \"%s %s <value>\", set the environment variable or use a default value (${%s:-<value>}).", SetDirective, name, name),
+				token:   match,
+			}
+		}
+
+		return ""
+	}
+
+	expanded := ""
+	for len(text) > 0 {
+		match, name, defaultValue, hasDefaultValue := scanUnixVariable(text)
+		if match == "" {
+			expanded += text[:1]
+			text = text[1:]
+			continue
+		}
+
+		expanded += replaceVariable(match, name, defaultValue, hasDefaultValue)
+		text = text[len(match):]
+	}
+
+	text = expanded
+
+	text = WindowsVariablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		return replaceVariable(match, WindowsVariablePattern.FindStringSubmatch(match)[1], "", false)
+	})
+
+	return text, err
+}
+
+// scanUnixVariable returns the $NAME, ${NAME} or ${NAME:-default} variable at the beginning
+// of the supplied text (an empty match if there is none). The default value ends at the
+// matching closing brace, so it can contain variables in braces as well.
+func scanUnixVariable(text string) (match, name, defaultValue string, hasDefaultValue bool) {
+
+	if !strings.HasPrefix(text, "${") {
+		if strings.HasPrefix(text, "$") {
+			name = unixVariableNamePattern.FindString(text[1:])
+		}
+
+		if name == "" {
+			return "", "", "", false
+		}
+
+		return "$" + name, name, "", false
+	}
+
+	name = unixVariableNamePattern.FindString(text[2:])
+	rest := text[2+len(name):]
+	switch {
+
+	case name == "":
+		return "", "", "", false
+
+	case strings.HasPrefix(rest, "}"):
+		return "${" + name + "}", name, "", false
+
+	case strings.HasPrefix(rest, ":-"):
+		depth := 0
+		for index := len(":-"); index < len(rest); index++ {
+			switch rest[index] {
+
+			case '{':
+				depth++
+
+			case '}':
+				if depth == 0 {
+					return text[:2+len(name)+index+1], name, rest[len(":-"):index], true
+				}
+
+				depth--
+
+			}
+		}
+
+	}
+
+	return "", "", "", false
+}
+
+// getTemplateData returns the built-in variables and all variables declared in the dotman file.
+func (variables variables) getTemplateData() map[string]string {
+	data := make(map[string]string)
+	for _, name := range builtInVariableNames {
+		if value, exists := getBuiltInVariable(name); exists {
+			data[name] = value
+		}
+	}
+
+	for name, value := range variables {
+		data[name] = value
+	}
+
+	return data
+}
+
+func getBuiltInVariable(name string) (string, bool) {
+	switch name {
+
+	case "HOME":
+		if homeDirectory, err := fs.GetUserHomeDirectory(); err == nil {
+			return homeDirectory, true
+		}
+
+	case "XDG_CONFIG_HOME":
+		// the default of the XDG Base Directory Specification
+		if homeDirectory, err := fs.GetUserHomeDirectory(); err == nil {
+			return filepath.Join(homeDirectory, ".config"), true
+		}
+
+	case "HOSTNAME":
+		if hostname, err := os.Hostname(); err == nil {
+			return hostname, true
+		}
+
+	case "OS":
+		return runtime.GOOS, true
+
+	}
+
+	return "", false
+}
@@ -0,0 +1,102 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestVariablesExpand(t *testing.T) {
+
+	t.Setenv("DOTMAN_TEST_ENV", "env")
+	t.Setenv("DOTMAN_TEST_OVERRIDDEN", "env")
+	t.Setenv("DOTMAN_TEST_EMPTY", "")
+	t.Setenv("DOTMAN_TEST_UNDEFINED", "")
+
+	variables := newVariables()
+	variables.set("NAME", "value")
+	variables.set("DOTMAN_TEST_OVERRIDDEN", "declared")
+	variables.set("DIRECTORY", "/path/to")
+
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"plain/path", "plain/path"},
+		{"$NAME", "value"},
+		{"${NAME}", "value"},
+		{"$NAME/file", "value/file"},
+		{"${NAME}file", "valuefile"},
+		{"%NAME%", "value"},
+		{"$DIRECTORY/$NAME.conf", "/path/to/value.conf"},
+		{"$DOTMAN_TEST_ENV", "env"},
+		{"$DOTMAN_TEST_OVERRIDDEN", "declared"},
+		{"${DOTMAN_TEST_UNDEFINED:-default}", "default"},
+		{"${DOTMAN_TEST_EMPTY:-default}", "default"},
+		{"${DOTMAN_TEST_UNDEFINED:-}", ""},
+		{"${DOTMAN_TEST_UNDEFINED:-$NAME}", "value"},
+		{"${NAME:-default}", "value"},
+		{"${DOTMAN_TEST_UNDEFINED:-${NAME}}/file", "value/file"},
+		{"${DOTMAN_TEST_UNDEFINED:-${DOTMAN_TEST_EMPTY:-default}}", "default"},
+		{"${DOTMAN_TEST_UNDEFINED:-a{b}c}", "a{b}c"},
+		{"${NAME:-${DOTMAN_TEST_UNDEFINED}}", "value"},
+		{"$OS", runtime.GOOS},
+
+		// capture group references and lone characters are kept
+		{"~/.$1", "~/.$1"},
+		{"${1}rc", "${1}rc"},
+		{"100%", "100%"},
+		{"$", "$"},
+		{"${NAME", "${NAME"},
+		{"${DOTMAN_TEST_UNDEFINED:-${NAME}", "${DOTMAN_TEST_UNDEFINED:-value"},
+	}
+
+	for _, test := range tests {
+		expanded, err := variables.expand(test.text)
+		if err != nil {
+			t.Errorf("expand(%q) failed: %s", test.text, err)
+			continue
+		}
+
+		if expanded != test.expected {
+			t.Errorf("expand(%q) = %q, expected %q", test.text, expanded, test.expected)
+		}
+	}
+}
+
+func TestVariablesExpandUndefined(t *testing.T) {
+
+	t.Setenv("DOTMAN_TEST_UNDEFINED", "")
+	t.Setenv("DOTMAN_TEST_EMPTY", "")
+
+	variables := newVariables()
+	variables.set("DOTMAN_TEST_EMPTY", "")
+
+	tests := []struct {
+		text          string
+		expectedToken string
+	}{
+		{"$DOTMAN_TEST_UNDEFINED", "$DOTMAN_TEST_UNDEFINED"},
+		{"prefix/${DOTMAN_TEST_UNDEFINED}/suffix", "${DOTMAN_TEST_UNDEFINED}"},
+		{"%DOTMAN_TEST_UNDEFINED%", "%DOTMAN_TEST_UNDEFINED%"},
+		{"$DOTMAN_TEST_EMPTY", "$DOTMAN_TEST_EMPTY"},
+		{"${DOTMAN_TEST_EMPTY:-$DOTMAN_TEST_UNDEFINED}", "$DOTMAN_TEST_UNDEFINED"},
+		{"${DOTMAN_TEST_EMPTY:-${DOTMAN_TEST_UNDEFINED}}", "${DOTMAN_TEST_UNDEFINED}"},
+	}
+
+	for _, test := range tests {
+		expanded, err := variables.expand(test.text)
+		parseError, isParseError := err.(*ParseError)
+		if !isParseError {
+			t.Errorf("expand(%q) = %q, %v, expected a parse error", test.text, expanded, err)
+			continue
+		}
+
+		if parseError.token != test.expectedToken {
+			t.Errorf("expand(%q) reports the token %q, expected %q", test.text, parseError.token, test.expectedToken)
+		}
+	}
+}
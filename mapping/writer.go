@@ -0,0 +1,196 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// backslashes which would be read as escaped characters outside of quotes
+	escapedCharacterPattern = regexp.MustCompile(`\\[ \t"'#]`)
+
+	// two or more slashes in a path
+	repeatedSlashesPattern = regexp.MustCompile(`//+`)
+)
+
+// FormatText returns the supplied statements in the canonical format of a dotman file:
+// the columns are separated by two spaces, the source and target columns of the path map
+// entries in each block of lines (separated by empty lines) are aligned, columns are only
+// quoted if necessary, options are sorted and repeated or trailing slashes are removed from paths.
+func FormatText(statements []*Statement) (string, error) {
+
+	type block struct {
+		sourceWidth int
+		targetWidth int
+	}
+
+	// the columns of each line and the block it belongs to
+	lines := make([][]string, 0, len(statements))
+	lineBlocks := make([]*block, 0, len(statements))
+	currentBlock := &block{}
+
+	addLine := func(columns ...string) {
+		lines = append(lines, columns)
+		lineBlocks = append(lineBlocks, currentBlock)
+	}
+
+	for _, statement := range statements {
+
+		if statement.err != nil {
+			return "", statement.newError(statement.err)
+		}
+
+		// empty lines start a new block
+		if statement.isEmptyLine {
+			currentBlock = &block{}
+			addLine("")
+			continue
+		}
+
+		// module metadata
+		if statement.isVerbatim {
+			addLine(statement.text)
+			continue
+		}
+
+		if statement.Comment != "" || statement.IsComment() {
+			for _, comment := range strings.Split(statement.Comment, "\n") {
+				addLine("#" + comment)
+			}
+		}
+
+		switch {
+
+		case statement.IsInclude():
+			addLine(IncludeDirective + " " + quoteWord(normalizeSlashes(statement.Include)))
+
+		case statement.IsSet():
+			addLine(SetDirective + " " + statement.Set + " " + quoteWord(statement.Value))
+
+		case statement.IsAuto() && statement.Auto == DefaultAutoTarget:
+			addLine(AutoDirective)
+
+		case statement.IsAuto():
+			addLine(AutoDirective + " " + quoteWord(normalizeSlashes(statement.Auto)))
+
+		case statement.IsEntry():
+			columns, err := getTextColumns(statement)
+			if err != nil {
+				return "", statement.newError(err)
+			}
+
+			if width := len([]rune(columns[0])); width > currentBlock.sourceWidth {
+				currentBlock.sourceWidth = width
+			}
+
+			if width := len([]rune(columns[1])); len(columns) > 2 && width > currentBlock.targetWidth {
+				currentBlock.targetWidth = width
+			}
+
+			addLine(columns...)
+
+		}
+	}
+
+	text := ""
+	for lineIndex, columns := range lines {
+		line := columns[0]
+		for index, column := range columns[1:] {
+
+			padding := 2
+			switch index {
+			case 0:
+				padding += lineBlocks[lineIndex].sourceWidth - len([]rune(columns[index]))
+			case 1:
+				padding += lineBlocks[lineIndex].targetWidth - len([]rune(columns[index]))
+			}
+
+			line += strings.Repeat(" ", padding) + column
+		}
+
+		text += line + "\n"
+	}
+
+	return text, nil
+}
+
+// getTextColumns returns the columns of the supplied path map entry.
+func getTextColumns(statement *Statement) ([]string, error) {
+
+	// sources which look like a "set" directive get a "./" prefix (directives are separated
+	// from their arguments by single spaces, so other sources like "auto" need no prefix)
+	source := normalizeSlashes(statement.Source)
+	if words := strings.Fields(source); len(words) > 1 && words[0] == SetDirective {
+		source = "./" + source
+	}
+
+	columns := []string{quoteColumn(source), quoteColumn(normalizeSlashes(statement.Target))}
+
+	// patterns which would be read as an option (e.g. from a manifest) get a "glob:" prefix
+	if pattern := statement.Pattern; pattern != "" {
+		if isOption(pattern) {
+			pattern = GlobPatternPrefix + pattern
+		}
+
+		columns = append(columns, quoteColumn(pattern))
+	}
+
+	// the options in their canonical form (the same as after a conversion to a manifest)
+	options, err := statement.getOptionColumns()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, option := range options {
+		columns = append(columns, quoteColumn(option))
+	}
+
+	return columns, nil
+}
+
+// quoteColumn puts the supplied column in double quotes if it would not be read back as it is.
+func quoteColumn(column string) string {
+	if column == "" ||
+		strings.TrimSpace(column) != column ||
+		strings.Contains(column, "  ") ||
+		strings.ContainsAny(column, "\t\n") ||
+		strings.HasPrefix(column, `"`) ||
+		strings.HasPrefix(column, "'") ||
+		strings.HasPrefix(column, "#") ||
+		strings.HasPrefix(column, "@") ||
+		escapedCharacterPattern.MatchString(column) {
+
+		return quote(column)
+	}
+
+	return column
+}
+
+// quoteWord puts the supplied argument of a directive in double quotes if it contains white space.
+func quoteWord(word string) string {
+	if strings.ContainsAny(word, " \t\n") {
+		return quote(word)
+	}
+
+	return quoteColumn(word)
+}
+
+// normalizeSlashes removes repeated and trailing slashes from the supplied path.
+func normalizeSlashes(path string) string {
+	path = repeatedSlashesPattern.ReplaceAllString(path, "/")
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+
+	return path
+}
+
+func quote(text string) string {
+	text = strings.Replace(text, `\`, `\\`, -1)
+	text = strings.Replace(text, `"`, `\"`, -1)
+	return `"` + text + `"`
+}
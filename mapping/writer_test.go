@@ -0,0 +1,130 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mapping
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readTestStatements returns the statements of a dotman file with the supplied content.
+func readTestStatements(t *testing.T, text string) []*Statement {
+	path := filepath.Join(t.TempDir(), "dotman")
+	if err := ioutil.WriteFile(path, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := readTextStatements(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return statements
+}
+
+// getCanonicalColumns returns the meaning of the supplied statement in a comparable form.
+func getCanonicalColumns(t *testing.T, statement *Statement) []string {
+	options, err := statement.getOptionColumns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append([]string{
+		statement.Comment, normalizeSlashes(statement.Include), statement.Set, statement.Value, normalizeSlashes(statement.Auto),
+		normalizeSlashes(statement.Source), normalizeSlashes(statement.Target), statement.Pattern,
+	}, options...)
+}
+
+func TestFormatText(t *testing.T) {
+
+	tests := []struct {
+		text      string
+		formatted string
+	}{
+		{"vimrc\t~/.vimrc\n", "vimrc  ~/.vimrc\n"},
+		{"a  ~/.a\nlonger\t\t~/.longer  link\n", "a       ~/.a\nlonger  ~/.longer  link\n"},
+		{"a  ~/.a\n\nlonger  ~/.longer\n", "a  ~/.a\n\nlonger  ~/.longer\n"},
+		{"bin//tools/  ~/bin/\n", "bin/tools  ~/bin\n"},
+		{"vim  ~/.vim  mode=0600  !*.swp  link\n", "vim  ~/.vim  exclude=*.swp  link  mode=0600\n"},
+		{"vim  ~/.vim  *.vim  optional\n", "vim  ~/.vim  *.vim  optional\n"},
+		{"vim  ~/.vim  foo=bar  link\n", "vim  ~/.vim  foo=bar  link\n"},
+		{"vim  ~/.vim  glob:link  link\n", "vim  ~/.vim  glob:link  link\n"},
+		{"\"my  file\"  ~/x\n", "\"my  file\"  ~/x\n"},
+		{"'#notes'  ~/notes\n", "\"#notes\"  ~/notes\n"},
+		{"# comment\n#\n@description A module\n", "# comment\n#\n@description A module\n"},
+		{"set NAME value\ninclude other\n", "set NAME value\ninclude other\n"},
+		{"set NAME \"a  b\"\n", "set NAME \"a  b\"\n"},
+		{"set NAME a  b\n", "set NAME \"a  b\"\n"},
+		{"set NAME a \"b\"\n", "set NAME \"a \\\"b\\\"\"\n"},
+		{"set   ~/.set\n", "set  ~/.set\n"},
+		{"set  NAME  value\n", "set  NAME  value\n"},
+		{"./set NAME  value\n", "./set NAME  value\n"},
+		{"auto\nauto ~/.config/\n", "auto\nauto ~/.config\n"},
+		{"auto  ~/.autorc\n", "auto  ~/.autorc\n"},
+		{"auto\t~/.autorc\n", "auto  ~/.autorc\n"},
+		{"\"auto\"  ~/.autorc\n", "auto  ~/.autorc\n"},
+		{"./auto  ~/.autorc\n", "./auto  ~/.autorc\n"},
+		{"./include  ~/.include\n", "./include  ~/.include\n"},
+		{"include   ~/.include\n", "include  ~/.include\n"},
+		{"include\t~/.include\n", "include  ~/.include\n"},
+		{"\n\nvimrc  ~/.vimrc\n\n", "\n\nvimrc  ~/.vimrc\n\n"},
+	}
+
+	for _, test := range tests {
+		statements := readTestStatements(t, test.text)
+
+		formatted, err := FormatText(statements)
+		if err != nil {
+			t.Errorf("FormatText(%q) failed: %s", test.text, err)
+			continue
+		}
+
+		if formatted != test.formatted {
+			t.Errorf("FormatText(%q) = %q, expected %q", test.text, formatted, test.formatted)
+			continue
+		}
+
+		// formatting a formatted file changes nothing
+		formattedStatements := readTestStatements(t, formatted)
+		if reformatted, err := FormatText(formattedStatements); err != nil || reformatted != formatted {
+			t.Errorf("FormatText(%q) = %q, %v, expected the text to stay the same", formatted, reformatted, err)
+		}
+
+		// formatting doesn't change the meaning of the statements
+		original, result := make([][]string, 0), make([][]string, 0)
+		for _, statement := range statements {
+			if !statement.IsEmptyLine() {
+				original = append(original, getCanonicalColumns(t, statement))
+			}
+		}
+
+		for _, statement := range formattedStatements {
+			if !statement.IsEmptyLine() {
+				result = append(result, getCanonicalColumns(t, statement))
+			}
+		}
+
+		if !reflect.DeepEqual(original, result) {
+			t.Errorf("FormatText(%q) changed the statements from %q to %q", test.text, original, result)
+		}
+	}
+}
+
+func TestFormatTextPatternsLikeOptions(t *testing.T) {
+
+	// e.g. the pattern of a manifest entry
+	statements := []*Statement{{Source: "vim", Target: "~/.vim", Pattern: "link"}}
+
+	formatted, err := FormatText(statements)
+	if err != nil {
+		t.Fatalf("FormatText failed: %s", err)
+	}
+
+	if expected := "vim  ~/.vim  glob:link\n"; formatted != expected {
+		t.Errorf("FormatText = %q, expected %q", formatted, expected)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sortByDependencies orders the supplied modules so that every module comes after the modules
+// it requires. Modules without dependencies between them keep their original order. Missing
+// dependencies and circular dependencies are ignored here (see WithDependencies).
+func sortByDependencies(modules []*Module) []*Module {
+
+	modulesByName := make(map[string]*Module)
+	for _, module := range modules {
+		modulesByName[module.name] = module
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	states := make(map[*Module]int)
+	sortedModules := make([]*Module, 0, len(modules))
+
+	var visit func(module *Module)
+	visit = func(module *Module) {
+		if states[module] != unvisited {
+			return
+		}
+
+		states[module] = visiting
+		for _, dependencyName := range module.Metadata.Requires {
+			if dependency, exists := modulesByName[dependencyName]; exists {
+				visit(dependency)
+			}
+		}
+
+		states[module] = visited
+		sortedModules = append(sortedModules, module)
+	}
+
+	for _, module := range modules {
+		visit(module)
+	}
+
+	return sortedModules
+}
+
+// WithDependencies returns the supplied modules together with all modules
+// they require (directly or indirectly) in the order of the collection.
+// Modules with a missing or circular dependency are left out and their
+// problems are returned as the error.
+func (collection *Collection) WithDependencies(modules []*Module) ([]*Module, error) {
+
+	modulesByName := make(map[string]*Module)
+	for _, module := range collection.Collection {
+		modulesByName[module.name] = module
+	}
+
+	// the problem of every module whose dependencies have been resolved (nil if there is none)
+	problems := make(map[*Module]error)
+	visiting := make(map[*Module]bool)
+
+	// path contains the modules which are currently being visited (for the cycle error message)
+	var resolve func(module *Module, path []string) error
+	resolve = func(module *Module, path []string) error {
+
+		if visiting[module] {
+			return fmt.Errorf("The modules have a circular dependency: %s.", strings.Join(append(path, module.name), " → "))
+		}
+
+		if problem, isResolved := problems[module]; isResolved {
+			return problem
+		}
+
+		visiting[module] = true
+		var problem error
+		for _, dependencyName := range module.Metadata.Requires {
+			dependency, exists := modulesByName[dependencyName]
+			if !exists {
+				problem = fmt.Errorf("The module %q requires the module %q which does not exist or does not support this operating system.", module.name, dependencyName)
+				break
+			}
+
+			if problem = resolve(dependency, append(path, module.name)); problem != nil {
+				break
+			}
+		}
+
+		visiting[module] = false
+		problems[module] = problem
+		return problem
+	}
+
+	required := make(map[*Module]bool)
+
+	var add func(module *Module)
+	add = func(module *Module) {
+		if required[module] {
+			return
+		}
+
+		required[module] = true
+		for _, dependencyName := range module.Metadata.Requires {
+			add(modulesByName[dependencyName])
+		}
+	}
+
+	errors := make(Errors, 0)
+	for _, module := range modules {
+		if problem := resolve(module, []string{}); problem != nil {
+			errors = append(errors, fmt.Errorf("Skipping the module %q. %s", module, problem))
+			continue
+		}
+
+		add(module)
+	}
+
+	modulesWithDependencies := make([]*Module, 0, len(required))
+	for _, module := range collection.Collection {
+		if required[module] {
+			modulesWithDependencies = append(modulesWithDependencies, module)
+		}
+	}
+
+	if len(errors) > 0 {
+		return modulesWithDependencies, errors
+	}
+
+	return modulesWithDependencies, nil
+}
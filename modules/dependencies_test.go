@@ -0,0 +1,101 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modules
+
+import (
+	"strings"
+	"testing"
+)
+
+// newTestCollection creates a collection of modules from "name:dependency,dependency" definitions.
+func newTestCollection(definitions ...string) *Collection {
+	modules := make([]*Module, 0)
+	for _, definition := range definitions {
+		metadata := newMetadata()
+		name := definition
+		if index := strings.Index(definition, ":"); index != -1 {
+			name = definition[:index]
+			metadata.Requires = strings.Split(definition[index+1:], ",")
+		}
+
+		modules = append(modules, &Module{name: name, Metadata: metadata})
+	}
+
+	return &Collection{Collection: sortByDependencies(modules)}
+}
+
+func getNames(modules []*Module) string {
+	names := make([]string, 0, len(modules))
+	for _, module := range modules {
+		names = append(names, module.name)
+	}
+
+	return strings.Join(names, " ")
+}
+
+func (collection *Collection) get(names ...string) []*Module {
+	modules := make([]*Module, 0)
+	for _, name := range names {
+		for _, module := range collection.Collection {
+			if module.name == name {
+				modules = append(modules, module)
+			}
+		}
+	}
+
+	return modules
+}
+
+func TestSortByDependencies(t *testing.T) {
+
+	tests := []struct {
+		definitions []string
+		expected    string
+	}{
+		{[]string{"a", "b", "c"}, "a b c"},
+		{[]string{"a:b", "b", "c"}, "b a c"},
+		{[]string{"a:b,c", "b:c", "c"}, "c b a"},
+		{[]string{"a:missing", "b"}, "a b"},
+		{[]string{"a:b", "b:a", "c"}, "b a c"},
+	}
+
+	for _, test := range tests {
+		collection := newTestCollection(test.definitions...)
+		if names := getNames(collection.Collection); names != test.expected {
+			t.Errorf("sortByDependencies(%v) = %q, expected %q", test.definitions, names, test.expected)
+		}
+	}
+}
+
+func TestWithDependencies(t *testing.T) {
+
+	collection := newTestCollection("a:b", "b:c", "c", "d:missing", "e:d", "f:g", "g:f", "h")
+
+	tests := []struct {
+		selected       []string
+		expected       string
+		expectedErrors int
+	}{
+		{[]string{"h"}, "h", 0},
+		{[]string{"a"}, "c b a", 0},
+		{[]string{"b", "h"}, "c b h", 0},
+		{[]string{"d"}, "", 1},
+		{[]string{"e", "h"}, "h", 1},
+		{[]string{"f", "a"}, "c b a", 1},
+		{[]string{"d", "e", "f", "g"}, "", 4},
+	}
+
+	for _, test := range tests {
+		modules, err := collection.WithDependencies(collection.get(test.selected...))
+		if names := getNames(modules); names != test.expected {
+			t.Errorf("WithDependencies(%v) = %q, expected %q", test.selected, names, test.expected)
+		}
+
+		errors, _ := err.(Errors)
+		if len(errors) != test.expectedErrors {
+			t.Errorf("WithDependencies(%v) returned %d errors (%v), expected %d", test.selected, len(errors), err, test.expectedErrors)
+		}
+	}
+}
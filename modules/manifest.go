@@ -0,0 +1,151 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/andreaskoch/dotman/mapping"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// the format of dotman files
+	TextFormat = "text"
+
+	// the format of dotman.json manifests
+	JSONFormat = "json"
+)
+
+// manifest is the content of a dotman.json file: the module metadata and
+// the same statements as in a dotman file.
+type manifest struct {
+	Description      string   `json:"description,omitempty"`
+	Tags             []string `json:"tags,omitempty"`
+	OperatingSystems []string `json:"os,omitempty"`
+	Author           string   `json:"author,omitempty"`
+	Requires         []string `json:"requires,omitempty"`
+
+	Entries []*mapping.Statement `json:"entries"`
+}
+
+func readManifestMetadata(manifestFilePath string) (*Metadata, error) {
+
+	content, err := ioutil.ReadFile(manifestFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var moduleManifest manifest
+	if err := json.Unmarshal(content, &moduleManifest); err != nil {
+		return newMetadata(), mapping.ParseErrors{mapping.NewJSONError(manifestFilePath, content, err)}
+	}
+
+	metadata := &Metadata{
+		Description:      moduleManifest.Description,
+		Tags:             append(make([]string, 0), moduleManifest.Tags...),
+		Author:           moduleManifest.Author,
+		OperatingSystems: append(make([]string, 0), moduleManifest.OperatingSystems...),
+		Requires:         append(make([]string, 0), moduleManifest.Requires...),
+	}
+
+	return metadata, nil
+}
+
+// Format returns the format of the module file (TextFormat or JSONFormat).
+func (module *Module) Format() string {
+	if mapping.IsManifest(module.moduleFile) {
+		return JSONFormat
+	}
+
+	return TextFormat
+}
+
+// Convert returns the path and the content of the module file in the supplied format.
+// Empty lines are kept (as null entries of a manifest) and the metadata is moved to the
+// beginning of a dotman file. Modules which include other files can only be converted to
+// the format they already have, because the included files would keep their format.
+func (module *Module) Convert(format string) (string, []byte, error) {
+
+	statements, err := mapping.ReadStatements(module.moduleFile)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if format != module.Format() {
+		for _, statement := range statements {
+			if statement.IsInclude() {
+				return "", nil, fmt.Errorf("The module includes the file %q. Modules which include other files cannot be converted.", statement.Include)
+			}
+		}
+	}
+
+	switch format {
+
+	case JSONFormat:
+		entries := make([]*mapping.Statement, 0, len(statements))
+		for _, statement := range statements {
+			if statement.IsEmptyLine() {
+				statement = nil // empty lines are null entries
+			}
+
+			entries = append(entries, statement)
+		}
+
+		moduleManifest := manifest{
+			Description:      module.Metadata.Description,
+			Tags:             module.Metadata.Tags,
+			OperatingSystems: module.Metadata.OperatingSystems,
+			Author:           module.Metadata.Author,
+			Requires:         module.Metadata.Requires,
+			Entries:          entries,
+		}
+
+		buffer := new(bytes.Buffer)
+		encoder := json.NewEncoder(buffer)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(moduleManifest); err != nil {
+			return "", nil, err
+		}
+
+		return filepath.Join(module.directory, ManifestFileName), buffer.Bytes(), nil
+
+	case TextFormat:
+		text, err := mapping.FormatText(statements)
+		if err != nil {
+			return "", nil, err
+		}
+
+		// the empty line after the metadata is one of the statements
+		text = formatMetadata(module.Metadata) + text
+
+		return filepath.Join(module.directory, ModuleFileName), []byte(text), nil
+
+	}
+
+	return "", nil, fmt.Errorf("%q is not a known format. Please use %q or %q.", format, TextFormat, JSONFormat)
+}
+
+// formatMetadata returns the @-lines of a dotman file for the supplied metadata.
+func formatMetadata(metadata *Metadata) string {
+	lines := ""
+	addLine := func(directive, value string) {
+		if value != "" {
+			lines += MetadataPrefix + directive + " " + value + "\n"
+		}
+	}
+
+	addLine(DescriptionDirective, metadata.Description)
+	addLine(TagsDirective, strings.Join(metadata.Tags, ", "))
+	addLine(OSDirective, strings.Join(metadata.OperatingSystems, ", "))
+	addLine(AuthorDirective, metadata.Author)
+	addLine(RequiresDirective, strings.Join(metadata.Requires, ", "))
+
+	return lines
+}
@@ -0,0 +1,94 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modules
+
+import (
+	"github.com/andreaskoch/dotman/mapping"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertRoundTrip(t *testing.T) {
+
+	tests := []string{
+		"vimrc  ~/.vimrc\n",
+		"@description My module\n@tags a, b\n\n# vim\n#\nvimrc  ~/.vimrc\nvim    ~/.vim  *.vim  link\n\n\nset NAME value\n\"a  b\"  ~/x  exclude=*.swp  mode=0600\nauto ~/.dot\n",
+		"@os linux\nvimrc  ~/.vimrc\n",
+		"\n\nbashrc  ~/.bashrc  optional\n\n",
+		"auto\nlinks  ~/.$1   re:^(.+)\\.symlink$\nvim    ~/.vim  exclude=*.log  !{a,b}\n",
+	}
+
+	for _, text := range tests {
+		directory := t.TempDir()
+		textFile := filepath.Join(directory, ModuleFileName)
+		if err := ioutil.WriteFile(textFile, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		// the text must be in the canonical format for an exact round trip
+		formattedText, err := mapping.FormatFile(textFile)
+		if err != nil {
+			t.Errorf("FormatFile(%q) failed: %s", text, err)
+			continue
+		}
+
+		if formattedText != text {
+			t.Errorf("The test case %q is not formatted (%q)", text, formattedText)
+			continue
+		}
+
+		module, err := newModule("test", directory)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		manifestFile, manifestContent, err := module.Convert(JSONFormat)
+		if err != nil {
+			t.Errorf("Converting %q to JSON failed: %s", text, err)
+			continue
+		}
+
+		os.Remove(textFile)
+		if err := ioutil.WriteFile(manifestFile, manifestContent, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		module, err = newModule("test", directory)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, convertedText, err := module.Convert(TextFormat); err != nil || string(convertedText) != text {
+			t.Errorf("Converting %q to JSON and back gives %q (%v)\nJSON: %s", text, convertedText, err, manifestContent)
+		}
+	}
+}
+
+func TestConvertRefusesIncludes(t *testing.T) {
+
+	directory := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(directory, ModuleFileName), []byte("include other\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(directory, "other"), []byte("vimrc  ~/.vimrc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	module, err := newModule("test", directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := module.Convert(JSONFormat); err == nil {
+		t.Errorf("Converting a module which includes another file succeeded, expected an error")
+	}
+
+	if _, _, err := module.Convert(TextFormat); err != nil {
+		t.Errorf("Formatting a module which includes another file failed: %s", err)
+	}
+}
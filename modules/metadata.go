@@ -0,0 +1,167 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modules
+
+import (
+	"fmt"
+	"github.com/andreaskoch/dotman/mapping"
+	"github.com/andreaskoch/dotman/util/fs"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const (
+	// the prefix of all metadata lines in a module file
+	MetadataPrefix = "@"
+
+	DescriptionDirective = "description"
+	TagsDirective        = "tags"
+	OSDirective          = "os"
+	AuthorDirective      = "author"
+	RequiresDirective    = "requires"
+)
+
+var (
+	// the separator between the values of a list directive (e.g. "@tags shell, terminal")
+	metadataListSeparatorPattern = regexp.MustCompile(`[\s,]+`)
+)
+
+// Metadata contains the information from the @-lines of a module file
+// (or the fields of a dotman.json manifest).
+type Metadata struct {
+	Description string
+	Tags        []string
+	Author      string
+
+	// the operating systems the module supports (e.g. "linux", "darwin"). All if empty.
+	OperatingSystems []string
+
+	// the names of the modules which must be deployed before this module
+	Requires []string
+}
+
+func newMetadata() *Metadata {
+	return &Metadata{
+		Tags:             make([]string, 0),
+		OperatingSystems: make([]string, 0),
+		Requires:         make([]string, 0),
+	}
+}
+
+// readMetadata returns the metadata of the supplied module file. Lines with errors are
+// reported as mapping.ParseErrors together with the metadata of the other lines.
+func readMetadata(moduleFilePath string) (*Metadata, error) {
+
+	// manifests contain the metadata as fields
+	if mapping.IsManifest(moduleFilePath) {
+		return readManifestMetadata(moduleFilePath)
+	}
+
+	file, err := os.Open(moduleFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	// lines with errors are skipped so the other lines can still be used
+	metadata := newMetadata()
+	parseErrors := make(mapping.ParseErrors, 0)
+	for lineNumber, line := range fs.GetLines(file) {
+
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, MetadataPrefix) {
+			continue
+		}
+
+		if err := metadata.set(line); err != nil {
+			parseErrors = append(parseErrors, &mapping.ParseError{File: moduleFilePath, Line: lineNumber + 1, Message: err.Error()})
+		}
+	}
+
+	if len(parseErrors) > 0 {
+		return metadata, parseErrors
+	}
+
+	return metadata, nil
+}
+
+// set assigns the value of the supplied metadata line (e.g. "@description My module").
+func (metadata *Metadata) set(line string) error {
+
+	directive := strings.TrimPrefix(line, MetadataPrefix)
+	value := ""
+	if index := strings.IndexAny(directive, " \t"); index != -1 {
+		value = strings.TrimSpace(directive[index:])
+		directive = directive[:index]
+	}
+
+	if value == "" {
+		return fmt.Errorf("The directive %q has no value.", line)
+	}
+
+	switch directive {
+
+	case DescriptionDirective:
+		metadata.Description = value
+
+	case TagsDirective:
+		metadata.Tags = append(metadata.Tags, getListValues(value)...)
+
+	case OSDirective:
+		metadata.OperatingSystems = append(metadata.OperatingSystems, getListValues(value)...)
+
+	case AuthorDirective:
+		metadata.Author = value
+
+	case RequiresDirective:
+		metadata.Requires = append(metadata.Requires, getListValues(value)...)
+
+	default:
+		return fmt.Errorf("%q is not a known directive.", MetadataPrefix+directive)
+
+	}
+
+	return nil
+}
+
+// IsSupported checks if the module supports the current operating system.
+func (metadata *Metadata) IsSupported() bool {
+	if len(metadata.OperatingSystems) == 0 {
+		return true
+	}
+
+	for _, operatingSystem := range metadata.OperatingSystems {
+		if strings.EqualFold(operatingSystem, runtime.GOOS) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasTag checks if the module has the supplied tag.
+func (metadata *Metadata) HasTag(tag string) bool {
+	for _, moduleTag := range metadata.Tags {
+		if strings.EqualFold(moduleTag, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getListValues(value string) []string {
+	values := make([]string, 0)
+	for _, listValue := range metadataListSeparatorPattern.Split(value, -1) {
+		if listValue != "" {
+			values = append(values, listValue)
+		}
+	}
+
+	return values
+}
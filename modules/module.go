@@ -7,35 +7,72 @@ package modules
 import (
 	"fmt"
 	"github.com/andreaskoch/dotman/mapping"
-	"github.com/andreaskoch/dotman/util/fs"
-	"path/filepath"
 )
 
-func newModule(directory, moduleFileName string) (*Module, error) {
+func newModule(name, directory string) (*Module, error) {
 
-	moduleFilePath := filepath.Join(directory, moduleFileName)
+	// find the dotman file or dotman.json manifest
+	moduleFilePath, err := getModuleFile(directory)
+	if err != nil {
+		return nil, err
+	}
 
-	// check if the module file exists
-	if !fs.FileExists(moduleFilePath) {
-		return nil, fmt.Errorf("Module file file %q does not exist.", moduleFilePath)
+	// modules with parse errors are kept so they can be used with -force
+	moduleErrors := make(mapping.ParseErrors, 0)
+
+	// read the metadata (the lines without errors are used even if other lines contain errors)
+	metadata, err := readMetadata(moduleFilePath)
+	metadataErrors, hasMetadataErrors := err.(mapping.ParseErrors)
+	if err != nil && !hasMetadataErrors {
+		return nil, fmt.Errorf("Unable to read the metadata of the dotman file. %s", err)
 	}
 
+	moduleErrors = append(moduleErrors, metadataErrors...)
+
 	// read the module file
 	modulePathMap, err := mapping.NewPathMap(moduleFilePath)
-	if err != nil {
+	parseErrors, hasParseErrors := err.(mapping.ParseErrors)
+	if err != nil && !hasParseErrors {
 		return nil, fmt.Errorf("Unable to read dotman file. %s", err)
 	}
 
+	for _, parseError := range parseErrors {
+
+		// manifests which are not valid JSON have no metadata and no entries
+		if containsError(metadataErrors, parseError) {
+			continue
+		}
+
+		moduleErrors = append(moduleErrors, parseError)
+	}
+
 	return &Module{
 		Map:        modulePathMap,
-		name:       filepath.Base(directory),
+		Metadata:   metadata,
+		Errors:     moduleErrors,
+		name:       name,
 		directory:  directory,
 		moduleFile: moduleFilePath,
 	}, nil
 }
 
+// containsError checks if the supplied errors contain an error at the position of the supplied error.
+func containsError(errors mapping.ParseErrors, err *mapping.ParseError) bool {
+	for _, existingError := range errors {
+		if existingError.File == err.File && existingError.Line == err.Line && existingError.Column == err.Column {
+			return true
+		}
+	}
+
+	return false
+}
+
 type Module struct {
-	Map *mapping.PathMap
+	Map      *mapping.PathMap
+	Metadata *Metadata
+
+	// the statements of the module file which cannot be used
+	Errors mapping.ParseErrors
 
 	name       string
 	directory  string
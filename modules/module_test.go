@@ -0,0 +1,97 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewModuleKeepsValidMetadata(t *testing.T) {
+
+	tests := []struct {
+		text             string
+		operatingSystems []string
+		tags             []string
+		errors           int
+	}{
+		{"@os plan9\n@bogus value\nvimrc  ~/.vimrc\n", []string{"plan9"}, []string{}, 1},
+		{"@bogus value\n@os plan9\n@tags a, b\n", []string{"plan9"}, []string{"a", "b"}, 1},
+		{"@os\n@tags a\n\"vimrc  ~/.vimrc\n", []string{}, []string{"a"}, 2},
+		{"@tags a\n@os linux\n", []string{"linux"}, []string{"a"}, 0},
+	}
+
+	for _, test := range tests {
+		directory := t.TempDir()
+		if err := ioutil.WriteFile(filepath.Join(directory, ModuleFileName), []byte(test.text), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		module, err := newModule("test", directory)
+		if err != nil {
+			t.Fatalf("newModule(%q) failed: %s", test.text, err)
+		}
+
+		if !reflect.DeepEqual(module.Metadata.OperatingSystems, test.operatingSystems) {
+			t.Errorf("newModule(%q) has the operating systems %q, expected %q", test.text, module.Metadata.OperatingSystems, test.operatingSystems)
+		}
+
+		if !reflect.DeepEqual(module.Metadata.Tags, test.tags) {
+			t.Errorf("newModule(%q) has the tags %q, expected %q", test.text, module.Metadata.Tags, test.tags)
+		}
+
+		if len(module.Errors) != test.errors {
+			t.Errorf("newModule(%q) has %d error(s), expected %d: %s", test.text, len(module.Errors), test.errors, module.Errors)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+
+	directory := t.TempDir()
+	moduleFiles := map[string]string{
+		"unsupported": "@os plan9\n@bogus value\nrc  ~/.rc\n",
+		"supported":   "@bogus value\nrc  ~/.rc\n",
+		"invalid":     "rc  ~/.rc\n",
+	}
+
+	for name, text := range moduleFiles {
+		if err := os.Mkdir(filepath.Join(directory, name), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(directory, name, ModuleFileName), []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a module with two module files cannot be loaded
+	if err := ioutil.WriteFile(filepath.Join(directory, "invalid", ManifestFileName), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		options LoadOptions
+		names   string
+	}{
+		{LoadOptions{MaxDepth: DefaultMaxDepth}, "supported"},
+		{LoadOptions{MaxDepth: DefaultMaxDepth, IncludeUnsupported: true}, "supported unsupported"},
+	}
+
+	for _, test := range tests {
+		collection, err := Load(directory, test.options)
+
+		if loadErrors, isLoadErrors := err.(Errors); !isLoadErrors || len(loadErrors) != 1 {
+			t.Errorf("Load(%+v) returned the error %#v, expected one error for the invalid module", test.options, err)
+		}
+
+		if names := getNames(collection.Collection); names != test.names {
+			t.Errorf("Load(%+v) returned the modules %q, expected %q", test.options, names, test.names)
+		}
+	}
+}
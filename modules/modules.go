@@ -12,49 +12,90 @@ import (
 
 const (
 	ModuleFileName = "dotman"
+
+	// the name of the module file in the JSON format
+	ManifestFileName = "dotman.json"
+
+	// the name of the file in the base directory which lists directories that don't contain modules
+	IgnoreFileName = ".dotmanignore"
+
+	// the default maximum depth of module directories below the base directory
+	DefaultMaxDepth = 3
 )
 
 type Collection struct {
 	BaseDirectory string
 	Collection    []*Module
+
+	// use modules whose module files contain errors
+	Force bool
 }
 
-func Load(directory string) (*Collection, error) {
+// Errors contains the problems of several modules.
+type Errors []error
+
+func (errors Errors) Error() string {
+	messages := make([]string, 0, len(errors))
+	for _, err := range errors {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// LoadOptions control which modules are loaded.
+type LoadOptions struct {
+	// the maximum depth of module directories below the base directory (1 = only the immediate sub-directories)
+	MaxDepth int
+
+	// include the modules which don't support the current operating system
+	IncludeUnsupported bool
+}
+
+// Load reads all modules in the supplied directory and its sub-directories.
+// If some of the modules cannot be read, the other modules are returned
+// together with the problems (as Errors).
+func Load(directory string, options LoadOptions) (*Collection, error) {
 
 	// check if the directory exists
 	if !fs.DirectoryExists(directory) {
 		return nil, fmt.Errorf("The directory %q does not exist.", directory)
 	}
 
-	// find all folders with module files in them (non-recursive)
-	moduleDirectories, err := getAllModuleDirectories(directory, ModuleFileName)
+	// find all folders with module files in them
+	moduleDirectories, err := getAllModuleDirectories(directory, options.MaxDepth)
 	if err != nil {
 		return nil, fmt.Errorf("Unable scan the directory %q for modules. Error: %s", directory, err)
 	}
 
 	// try to create modules from each module directory
 	modules := make([]*Module, 0)
-	errors := make([]string, 0)
+	errors := make(Errors, 0)
 	for _, moduleDirectory := range moduleDirectories {
 
-		module, err := newModule(moduleDirectory, ModuleFileName)
+		module, err := newModule(getModuleName(directory, moduleDirectory), moduleDirectory)
 		if err != nil {
-			errors = append(errors, err.Error())
+			errors = append(errors, err)
+			continue
+		}
+
+		// skip modules which don't support the current operating system
+		if !module.Metadata.IsSupported() && !options.IncludeUnsupported {
 			continue
 		}
 
 		modules = append(modules, module)
 	}
 
-	// create the module collection
+	// create the module collection (modules come after the modules they require)
 	collection := &Collection{
 		BaseDirectory: directory,
-		Collection:    modules,
+		Collection:    sortByDependencies(modules),
 	}
 
 	// partial success (not all modules could be read)
 	if len(errors) > 0 {
-		return collection, fmt.Errorf("%s", strings.Join(errors, ",\n"))
+		return collection, errors
 	}
 
 	// success
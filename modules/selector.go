@@ -0,0 +1,208 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// the prefix of a regular expression which is matched against the module names
+	RegexSelectorPrefix = "re:"
+
+	// the prefix of a tag
+	TagSelectorPrefix = "@"
+
+	// the prefix which negates a condition
+	NegationSelectorPrefix = "!"
+
+	// the separator between conditions which must all be met
+	AndSelectorSeparator = "&"
+)
+
+// A Selector selects modules by name, regular expression or tag.
+//
+// Every argument of a selector is a set of conditions separated by "&" which must
+// all be met. A module is selected if it meets any of the arguments. Arguments which
+// only consist of negated conditions (e.g. "!work") exclude modules from the selection.
+// An empty selector (or one with exclusions only) selects all modules.
+type Selector struct {
+	alternatives [][]*condition
+	exclusions   [][]*condition
+}
+
+type condition struct {
+	name       string
+	tag        string
+	expression *regexp.Regexp
+	isNegated  bool
+}
+
+// NewSelector creates a selector from the supplied command line arguments.
+func NewSelector(arguments []string) (*Selector, error) {
+
+	selector := &Selector{
+		alternatives: make([][]*condition, 0),
+		exclusions:   make([][]*condition, 0),
+	}
+
+	for _, argument := range arguments {
+
+		argument = strings.TrimSpace(argument)
+		if argument == "" {
+			continue
+		}
+
+		conditions := make([]*condition, 0)
+		isExclusion := true
+		for _, conditionText := range strings.Split(argument, AndSelectorSeparator) {
+			condition, err := newCondition(strings.TrimSpace(conditionText))
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a valid module selector. %s", argument, err)
+			}
+
+			isExclusion = isExclusion && condition.isNegated
+			conditions = append(conditions, condition)
+		}
+
+		if isExclusion {
+
+			// "!a&!b" excludes both a and b
+			for _, exclusion := range conditions {
+				exclusion.isNegated = false
+				selector.exclusions = append(selector.exclusions, []*condition{exclusion})
+			}
+
+			continue
+		}
+
+		selector.alternatives = append(selector.alternatives, conditions)
+	}
+
+	return selector, nil
+}
+
+func newCondition(text string) (*condition, error) {
+
+	condition := &condition{}
+
+	if strings.HasPrefix(text, NegationSelectorPrefix) {
+		condition.isNegated = true
+		text = strings.TrimSpace(strings.TrimPrefix(text, NegationSelectorPrefix))
+	}
+
+	switch {
+
+	case text == "":
+		return nil, fmt.Errorf("A condition must not be empty.")
+
+	case strings.HasPrefix(text, RegexSelectorPrefix):
+		expression, err := regexp.Compile(strings.TrimPrefix(text, RegexSelectorPrefix))
+		if err != nil {
+			return nil, err
+		}
+
+		condition.expression = expression
+
+	case strings.HasPrefix(text, TagSelectorPrefix):
+		condition.tag = strings.TrimPrefix(text, TagSelectorPrefix)
+		if condition.tag == "" {
+			return nil, fmt.Errorf("The tag must not be empty.")
+		}
+
+	default:
+		condition.name = text
+
+	}
+
+	return condition, nil
+}
+
+func (condition *condition) matches(module *Module) bool {
+
+	matches := false
+	switch {
+
+	case condition.expression != nil:
+		matches = condition.expression.MatchString(module.name)
+
+	case condition.tag != "":
+		matches = module.Metadata.HasTag(condition.tag)
+
+	default:
+		matches = module.name == condition.name
+
+	}
+
+	return matches != condition.isNegated
+}
+
+// Matches checks if the supplied module is selected.
+func (selector *Selector) Matches(module *Module) bool {
+
+	for _, exclusion := range selector.exclusions {
+		if matchesAll(module, exclusion) {
+			return false
+		}
+	}
+
+	if len(selector.alternatives) == 0 {
+		return true
+	}
+
+	for _, alternative := range selector.alternatives {
+		if matchesAll(module, alternative) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Select returns all modules of the collection which are selected.
+func (selector *Selector) Select(collection *Collection) []*Module {
+	selectedModules := make([]*Module, 0)
+	for _, module := range collection.Collection {
+		if selector.Matches(module) {
+			selectedModules = append(selectedModules, module)
+		}
+	}
+
+	return selectedModules
+}
+
+// UnknownNames returns the module names in the selector which don't exist in the supplied collection.
+func (selector *Selector) UnknownNames(collection *Collection) []string {
+
+	names := make(map[string]bool)
+	for _, module := range collection.Collection {
+		names[module.name] = true
+	}
+
+	unknownNames := make([]string, 0)
+	for _, conditionSets := range [][][]*condition{selector.alternatives, selector.exclusions} {
+		for _, conditions := range conditionSets {
+			for _, condition := range conditions {
+				if condition.name != "" && !names[condition.name] {
+					unknownNames = append(unknownNames, condition.name)
+				}
+			}
+		}
+	}
+
+	return unknownNames
+}
+
+func matchesAll(module *Module, conditions []*condition) bool {
+	for _, condition := range conditions {
+		if !condition.matches(module) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,78 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelector(t *testing.T) {
+
+	collection := &Collection{Collection: []*Module{
+		{name: "vim", Metadata: &Metadata{Tags: []string{"editor", "terminal"}}},
+		{name: "emacs", Metadata: &Metadata{Tags: []string{"Editor"}}},
+		{name: "shells/bash", Metadata: &Metadata{Tags: []string{"shell", "terminal"}}},
+		{name: "shells/zsh", Metadata: &Metadata{Tags: []string{"shell", "work"}}},
+		{name: "git", Metadata: newMetadata()},
+	}}
+
+	tests := []struct {
+		arguments []string
+		expected  string
+	}{
+		{[]string{}, "vim emacs shells/bash shells/zsh git"},
+		{[]string{"vim"}, "vim"},
+		{[]string{"vim", "git"}, "vim git"},
+		{[]string{"shells"}, ""},
+		{[]string{"re:^shells/"}, "shells/bash shells/zsh"},
+		{[]string{"re:sh"}, "shells/bash shells/zsh"},
+		{[]string{"@editor"}, "vim emacs"},
+		{[]string{"@shell&@terminal"}, "shells/bash"},
+		{[]string{"@shell&!@work"}, "shells/bash"},
+		{[]string{"!@work"}, "vim emacs shells/bash git"},
+		{[]string{"!vim&!git"}, "emacs shells/bash shells/zsh"},
+		{[]string{"@terminal", "!vim"}, "shells/bash"},
+		{[]string{"re:.", "!re:^shells/"}, "vim emacs git"},
+		{[]string{" vim ", ""}, "vim"},
+	}
+
+	for _, test := range tests {
+		selector, err := NewSelector(test.arguments)
+		if err != nil {
+			t.Errorf("NewSelector(%q) failed: %s", test.arguments, err)
+			continue
+		}
+
+		if names := getNames(selector.Select(collection)); names != test.expected {
+			t.Errorf("NewSelector(%q) selects %q, expected %q", test.arguments, names, test.expected)
+		}
+	}
+}
+
+func TestInvalidSelectors(t *testing.T) {
+	for _, arguments := range [][]string{{"!"}, {"vim&"}, {"@"}, {"re:("}, {"!@"}} {
+		if _, err := NewSelector(arguments); err == nil {
+			t.Errorf("NewSelector(%q) succeeded, expected an error", arguments)
+		}
+	}
+}
+
+func TestSelectorUnknownNames(t *testing.T) {
+
+	collection := &Collection{Collection: []*Module{
+		{name: "vim", Metadata: newMetadata()},
+		{name: "git", Metadata: newMetadata()},
+	}}
+
+	selector, err := NewSelector([]string{"vim", "vi", "!emacs", "@editor", "re:x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if unknownNames := strings.Join(selector.UnknownNames(collection), " "); unknownNames != "vi emacs" {
+		t.Errorf("UnknownNames() = %q, expected \"vi emacs\"", unknownNames)
+	}
+}
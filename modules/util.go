@@ -5,40 +5,194 @@
 package modules
 
 import (
+	"bufio"
 	"fmt"
 	"github.com/andreaskoch/dotman/util/fs"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 )
 
-func getAllModuleDirectories(baseDirectory, moduleFileName string) ([]string, error) {
+var (
+	// directories which never contain modules
+	ignoredDirectoryNames = []string{".git", ".backup"}
 
-	baseDirectoryEntries, err := ioutil.ReadDir(baseDirectory)
+	// the names of the files which turn a directory into a module
+	moduleFileNames = []string{ModuleFileName, ManifestFileName}
+)
+
+// getAllModuleDirectories returns the base directory (if it contains a module file) and all
+// directories below the base directory which contain a module file. The search does not descend
+// into module directories, directories below the supplied maximum depth or ignored directories.
+func getAllModuleDirectories(baseDirectory string, maxDepth int) ([]string, error) {
+
+	ignoreRules, err := readIgnoreFile(filepath.Join(baseDirectory, IgnoreFileName))
 	if err != nil {
-		return []string{}, fmt.Errorf("Cannot read directory %q.", baseDirectory)
+		return []string{}, err
 	}
 
-	// get al list of all directories (including the base directory)
 	moduleDirectories := make([]string, 0)
-	for _, entry := range baseDirectoryEntries {
 
-		// check the directory
-		if entry.IsDir() {
-			subDirectoryPath := filepath.Join(baseDirectory, entry.Name())
-			moduleFilePath := filepath.Join(subDirectoryPath, moduleFileName)
+	// add the base directory if it contains a module file
+	if containsModuleFile(baseDirectory) {
+		moduleDirectories = append(moduleDirectories, baseDirectory)
+	}
+
+	var walk func(directory string, depth int) error
+	walk = func(directory string, depth int) error {
+
+		entries, err := ioutil.ReadDir(directory)
+		if err != nil {
+			return fmt.Errorf("Cannot read directory %q.", directory)
+		}
+
+		for _, entry := range entries {
+
+			if !entry.IsDir() || isIgnoredDirectoryName(entry.Name()) {
+				continue
+			}
+
+			subDirectoryPath := filepath.Join(directory, entry.Name())
+			relativePath, _ := filepath.Rel(baseDirectory, subDirectoryPath)
+			if ignoreRules.matches(filepath.ToSlash(relativePath)) {
+				continue
+			}
 
 			// add the directory if it contains a module file
-			if fs.FileExists(moduleFilePath) {
+			if containsModuleFile(subDirectoryPath) {
 				moduleDirectories = append(moduleDirectories, subDirectoryPath)
+				continue
 			}
-		}
 
-		// add the base directory if it contains a projct file
-		if entry.Name() == moduleFileName {
-			moduleDirectories = append(moduleDirectories, baseDirectory)
+			if depth < maxDepth {
+				if err := walk(subDirectoryPath, depth+1); err != nil {
+					return err
+				}
+			}
 		}
 
+		return nil
+	}
+
+	if maxDepth > 0 {
+		if err := walk(baseDirectory, 1); err != nil {
+			return []string{}, err
+		}
 	}
 
 	return moduleDirectories, nil
 }
+
+// containsModuleFile checks if the supplied directory contains a dotman file or a dotman.json manifest.
+func containsModuleFile(directory string) bool {
+	for _, moduleFileName := range moduleFileNames {
+		if fs.FileExists(filepath.Join(directory, moduleFileName)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getModuleFile returns the path of the module file in the supplied directory.
+// A module can either have a dotman file or a dotman.json manifest but not both.
+func getModuleFile(directory string) (string, error) {
+	moduleFiles := make([]string, 0)
+	for _, moduleFileName := range moduleFileNames {
+		if path := filepath.Join(directory, moduleFileName); fs.FileExists(path) {
+			moduleFiles = append(moduleFiles, path)
+		}
+	}
+
+	switch len(moduleFiles) {
+
+	case 0:
+		return "", fmt.Errorf("The directory %q contains no module file.", directory)
+
+	case 1:
+		return moduleFiles[0], nil
+
+	}
+
+	return "", fmt.Errorf("The directory %q contains a %q and a %q file. Please remove one of them (see the \"convert\" command).", directory, ModuleFileName, ManifestFileName)
+}
+
+func isIgnoredDirectoryName(name string) bool {
+	for _, ignoredDirectoryName := range ignoredDirectoryNames {
+		if name == ignoredDirectoryName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getModuleName returns the path of the module directory relative to the
+// base directory (e.g. "editors/vim") or the name of the base directory.
+func getModuleName(baseDirectory, moduleDirectory string) string {
+	relativePath, err := filepath.Rel(baseDirectory, moduleDirectory)
+	if err != nil || relativePath == "." {
+		return filepath.Base(moduleDirectory)
+	}
+
+	return filepath.ToSlash(relativePath)
+}
+
+// ignoreRules contains the patterns of an ignore file. Patterns which contain
+// a slash are matched against the path relative to the base directory, all
+// other patterns are matched against the name of each directory.
+type ignoreRules []string
+
+func readIgnoreFile(path string) (ignoreRules, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ignoreRules{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the ignore file %q. %s", path, err)
+	}
+
+	defer file.Close()
+
+	rules := make(ignoreRules, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern := strings.Trim(line, "/")
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%q in the ignore file %q is not a valid pattern. %s", line, path, err)
+		}
+
+		rules = append(rules, pattern)
+	}
+
+	return rules, scanner.Err()
+}
+
+func (rules ignoreRules) matches(relativePath string) bool {
+	name := relativePath[strings.LastIndex(relativePath, "/")+1:]
+
+	for _, pattern := range rules {
+		if strings.Contains(pattern, "/") {
+			if matches, _ := filepath.Match(pattern, relativePath); matches {
+				return true
+			}
+
+			continue
+		}
+
+		if matches, _ := filepath.Match(pattern, name); matches {
+			return true
+		}
+	}
+
+	return false
+}
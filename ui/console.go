@@ -21,6 +21,6 @@ func Message(text string, args ...interface{}) {
 }
 
 func Fatal(text string, args ...interface{}) {
-	Message(text, args)
+	Message(text, args...)
 	os.Exit(2)
 }
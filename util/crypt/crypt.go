@@ -0,0 +1,288 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package crypt provides authenticated encryption of streams with a key which
+// is derived either from a passphrase or from the content of a key file.
+//
+// An encrypted stream starts with a header (magic, key type and a random salt)
+// followed by chunks of AES-256-GCM sealed data. Every chunk is authenticated
+// together with the header, its position and a flag which marks the last chunk,
+// so reordered, truncated or extended streams are detected.
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	keyTypePassphrase byte = 1
+	keyTypeKeyFile    byte = 2
+
+	saltSize  = 16
+	keySize   = 32
+	chunkSize = 64 * 1024
+
+	passphraseIterations = 600000
+)
+
+var (
+	magic = []byte("dotman-encrypted-v1\n")
+
+	headerSize = len(magic) + 1 + saltSize
+)
+
+// A Key contains the secret an encryption key is derived from.
+type Key struct {
+	keyType byte
+	secret  []byte
+}
+
+// NewPassphraseKey creates a key from the supplied passphrase.
+func NewPassphraseKey(passphrase string) *Key {
+	return &Key{
+		keyType: keyTypePassphrase,
+		secret:  []byte(passphrase),
+	}
+}
+
+// NewKeyFileKey creates a key from the content of the supplied key file.
+func NewKeyFileKey(keyFile string) (*Key, error) {
+	secret, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the key file %q. %s", keyFile, err)
+	}
+
+	secret = bytes.TrimSpace(secret)
+	if len(secret) < 16 {
+		return nil, fmt.Errorf("The key file %q is too short. It must contain at least 16 bytes.", keyFile)
+	}
+
+	return &Key{
+		keyType: keyTypeKeyFile,
+		secret:  secret,
+	}, nil
+}
+
+func (key *Key) String() string {
+	if key.keyType == keyTypeKeyFile {
+		return "key file"
+	}
+
+	return "passphrase"
+}
+
+// derive returns the encryption key for the supplied salt.
+func (key *Key) derive(salt []byte) ([]byte, error) {
+	if key.keyType == keyTypeKeyFile {
+		return hkdf.Key(sha256.New, key.secret, salt, "dotman", keySize)
+	}
+
+	return pbkdf2.Key(sha256.New, string(key.secret), salt, passphraseIterations, keySize)
+}
+
+// IsEncrypted checks if the supplied data starts with the header of an encrypted stream.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// NewWriter returns a writer which encrypts everything written to it with the
+// supplied key. The writer must be closed to write the final chunk.
+func NewWriter(writer io.Writer, key *Key) (io.WriteCloser, error) {
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	header[len(magic)] = key.keyType
+
+	salt := header[len(magic)+1:]
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encryptingWriter{
+		writer: writer,
+		header: header,
+		aead:   aead,
+		buffer: make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// NewReader returns a reader which decrypts the supplied encrypted stream.
+func NewReader(reader io.Reader, key *Key) (io.Reader, error) {
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(reader, header); err != nil || !IsEncrypted(header) {
+		return nil, fmt.Errorf("The data is not encrypted by dotman.")
+	}
+
+	if header[len(magic)] != key.keyType {
+		expectedKey := &Key{keyType: header[len(magic)]}
+		return nil, fmt.Errorf("The data has been encrypted with a %s but a %s was supplied.", expectedKey, key)
+	}
+
+	aead, err := newAEAD(key, header[len(magic)+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptingReader{
+		reader: bufio.NewReaderSize(reader, chunkSize+aead.Overhead()+1),
+		header: header,
+		aead:   aead,
+		chunk:  make([]byte, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+func newAEAD(key *Key, salt []byte) (cipher.AEAD, error) {
+	encryptionKey, err := key.derive(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// getNonce returns the nonce for the chunk with the supplied number.
+// Nonces never repeat because every stream uses its own salt and key.
+func getNonce(aead cipher.AEAD, chunkNumber uint64, isLastChunk bool) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-9:], chunkNumber)
+	if isLastChunk {
+		nonce[len(nonce)-1] = 1
+	}
+
+	return nonce
+}
+
+type encryptingWriter struct {
+	writer      io.Writer
+	header      []byte
+	aead        cipher.AEAD
+	buffer      []byte
+	chunkNumber uint64
+	closed      bool
+}
+
+func (writer *encryptingWriter) Write(p []byte) (int, error) {
+	if writer.closed {
+		return 0, fmt.Errorf("The writer has already been closed.")
+	}
+
+	written := 0
+	for len(p) > 0 {
+
+		// a full chunk is only written once more data arrives because the last chunk is sealed differently
+		if len(writer.buffer) == chunkSize {
+			if err := writer.writeChunk(false); err != nil {
+				return written, err
+			}
+		}
+
+		count := copy(writer.buffer[len(writer.buffer):chunkSize], p)
+		writer.buffer = writer.buffer[:len(writer.buffer)+count]
+		p = p[count:]
+		written += count
+	}
+
+	return written, nil
+}
+
+// Close writes the last chunk. It does not close the underlying writer.
+func (writer *encryptingWriter) Close() error {
+	if writer.closed {
+		return nil
+	}
+
+	writer.closed = true
+	return writer.writeChunk(true)
+}
+
+func (writer *encryptingWriter) writeChunk(isLastChunk bool) error {
+	nonce := getNonce(writer.aead, writer.chunkNumber, isLastChunk)
+	sealedChunk := writer.aead.Seal(nil, nonce, writer.buffer, writer.header)
+
+	writer.buffer = writer.buffer[:0]
+	writer.chunkNumber++
+
+	_, err := writer.writer.Write(sealedChunk)
+	return err
+}
+
+type decryptingReader struct {
+	reader      *bufio.Reader
+	header      []byte
+	aead        cipher.AEAD
+	chunk       []byte
+	plaintext   []byte
+	chunkNumber uint64
+	done        bool
+}
+
+func (reader *decryptingReader) Read(p []byte) (int, error) {
+	for len(reader.plaintext) == 0 {
+		if reader.done {
+			return 0, io.EOF
+		}
+
+		if err := reader.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	count := copy(p, reader.plaintext)
+	reader.plaintext = reader.plaintext[count:]
+	return count, nil
+}
+
+func (reader *decryptingReader) readChunk() error {
+
+	size, err := io.ReadFull(reader.reader, reader.chunk)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("The encrypted data is truncated.")
+	}
+
+	// the last chunk is either shorter than the others or followed by the end of the stream
+	isLastChunk := err == io.ErrUnexpectedEOF
+	if !isLastChunk {
+		if _, peekError := reader.reader.Peek(1); peekError == io.EOF {
+			isLastChunk = true
+		}
+	}
+
+	nonce := getNonce(reader.aead, reader.chunkNumber, isLastChunk)
+	plaintext, err := reader.aead.Open(reader.chunk[:0], nonce, reader.chunk[:size], reader.header)
+	if err != nil {
+		return fmt.Errorf("Unable to decrypt the data. Either the key is wrong or the data has been modified or truncated.")
+	}
+
+	reader.plaintext = plaintext
+	reader.chunkNumber++
+	reader.done = isLastChunk
+
+	return nil
+}
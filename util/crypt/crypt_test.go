@@ -0,0 +1,101 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package crypt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+const overhead = 16 // the size of the GCM authentication tag of each chunk
+
+func newTestKey(secret string) *Key {
+	return &Key{
+		keyType: keyTypeKeyFile,
+		secret:  []byte(secret),
+	}
+}
+
+func encrypt(t *testing.T, plaintext []byte, key *Key) []byte {
+	var buffer bytes.Buffer
+	writer, err := NewWriter(&buffer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writer.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func decrypt(ciphertext []byte, key *Key) ([]byte, error) {
+	reader, err := NewReader(bytes.NewReader(ciphertext), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(reader)
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+
+	key := newTestKey("0123456789abcdef")
+	sizes := []int{0, 1, 100, chunkSize - 1, chunkSize, chunkSize + 1, 3 * chunkSize}
+
+	for _, size := range sizes {
+		plaintext := bytes.Repeat([]byte("x"), size)
+
+		ciphertext := encrypt(t, plaintext, key)
+		if !IsEncrypted(ciphertext) {
+			t.Errorf("The encrypted data of %d bytes has no header", size)
+		}
+
+		decrypted, err := decrypt(ciphertext, key)
+		if err != nil {
+			t.Errorf("Unable to decrypt %d bytes: %s", size, err)
+			continue
+		}
+
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("The decrypted data of %d bytes differs from the original (%d bytes)", size, len(decrypted))
+		}
+	}
+}
+
+func TestDecryptionDetectsModifiedData(t *testing.T) {
+
+	key := newTestKey("0123456789abcdef")
+	ciphertext := encrypt(t, bytes.Repeat([]byte("x"), 2*chunkSize+10), key)
+	firstChunkEnd := headerSize + chunkSize + overhead
+
+	tests := []struct {
+		description string
+		data        []byte
+		key         *Key
+	}{
+		{"the wrong key", ciphertext, newTestKey("fedcba9876543210")},
+		{"a passphrase instead of a key file", ciphertext, NewPassphraseKey("0123456789abcdef")},
+		{"only the header", ciphertext[:headerSize], key},
+		{"a missing last chunk", ciphertext[:2*firstChunkEnd-headerSize], key},
+		{"a missing byte", ciphertext[:len(ciphertext)-1], key},
+		{"a cut in the first chunk", ciphertext[:firstChunkEnd-10], key},
+		{"an appended byte", append(append(make([]byte, 0), ciphertext...), 0), key},
+		{"a modified byte", append(append(make([]byte, 0), ciphertext[:firstChunkEnd]...), append([]byte{ciphertext[firstChunkEnd] ^ 1}, ciphertext[firstChunkEnd+1:]...)...), key},
+		{"plain data", []byte("not encrypted"), key},
+	}
+
+	for _, test := range tests {
+		if _, err := decrypt(test.data, test.key); err == nil {
+			t.Errorf("Decrypting data with %s succeeded, expected an error", test.description)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package crypt
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// IsEncryptedFile checks if the supplied file has been encrypted by dotman.
+func IsEncryptedFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+
+	defer file.Close()
+
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false
+	}
+
+	return IsEncrypted(header)
+}
+
+// KeyMatches checks if the supplied encrypted file can be decrypted with the supplied key.
+// Only the first chunk is decrypted, so a file whose later chunks have been modified still
+// matches the key. A file whose first chunk has been modified doesn't match any key.
+func KeyMatches(path string, key *Key) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+
+	defer file.Close()
+
+	reader, err := NewReader(file, key)
+	if err != nil {
+		return false, nil
+	}
+
+	if _, err := reader.Read(make([]byte, 1)); err != nil && err != io.EOF {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// EncryptFile writes the encrypted content of the source file to the target file.
+func EncryptFile(source, target string, key *Key) (success bool, err error) {
+	return transformFile(source, target, func(reader io.Reader, writer io.Writer) error {
+		encryptingWriter, err := NewWriter(writer, key)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(encryptingWriter, reader); err != nil {
+			return err
+		}
+
+		return encryptingWriter.Close()
+	})
+}
+
+// DecryptFile writes the decrypted content of the source file to the target file.
+func DecryptFile(source, target string, key *Key) (success bool, err error) {
+	return transformFile(source, target, func(reader io.Reader, writer io.Writer) error {
+		decryptingReader, err := NewReader(reader, key)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(writer, decryptingReader)
+		return err
+	})
+}
+
+// ReadFile returns the decrypted content of the supplied file.
+func ReadFile(path string, key *Key) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	reader, err := NewReader(file, key)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt %q. %s", path, err)
+	}
+
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt %q. %s", path, err)
+	}
+
+	return content, nil
+}
+
+// FilesAreEqual checks if the decrypted content of the encrypted file equals the content of the plain file.
+func FilesAreEqual(encryptedFile, plainFile string, key *Key) (bool, error) {
+	plainContent, err := ioutil.ReadFile(plainFile)
+	if err != nil {
+		return false, err
+	}
+
+	decryptedContent, err := ReadFile(encryptedFile, key)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(plainContent, decryptedContent), nil
+}
+
+// transformFile writes the content of the source file to the target file
+// using the supplied transformation. The decrypted or encrypted content is
+// only written to the target if the transformation succeeds. Existing targets
+// keep their permissions, new target files are only readable by the current user.
+func transformFile(source, target string, transform func(reader io.Reader, writer io.Writer) error) (success bool, err error) {
+	if !fs.IsFile(source) {
+		return false, fmt.Errorf("%q is not a file.", source)
+	}
+
+	sourceReader, err := os.Open(source)
+	if err != nil {
+		return false, err
+	}
+
+	defer sourceReader.Close()
+
+	// transform the content in memory so nothing is written if the transformation fails
+	var buffer bytes.Buffer
+	if err := transform(sourceReader, &buffer); err != nil {
+		return false, fmt.Errorf("Unable to process %q. %s", source, err)
+	}
+
+	if err := fs.WriteFileAtomically(target, &buffer, fs.GetFileMode(target, 0600)); err != nil {
+		return false, fmt.Errorf("Unable to write the target file %q. %s", target, err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package crypt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecryptFileModes(t *testing.T) {
+
+	directory := t.TempDir()
+	key := newTestKey("0123456789abcdef")
+
+	plainFile := filepath.Join(directory, "plain")
+	encryptedFile := filepath.Join(directory, "encrypted")
+	if err := ioutil.WriteFile(plainFile, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := EncryptFile(plainFile, encryptedFile, key); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description  string
+		existingMode os.FileMode // 0 if the target doesn't exist
+		expectedMode os.FileMode
+	}{
+		{"a new target", 0, 0600},
+		{"an existing private target", 0600, 0600},
+		{"an existing readable target", 0644, 0644},
+	}
+
+	for index, test := range tests {
+		target := filepath.Join(directory, "targets", string(rune('a'+index)), "netrc")
+		if test.existingMode != 0 {
+			os.MkdirAll(filepath.Dir(target), 0700)
+			if err := ioutil.WriteFile(target, []byte("old"), test.existingMode); err != nil {
+				t.Fatal(err)
+			}
+
+			// the umask doesn't apply to chmod
+			if err := os.Chmod(target, test.existingMode); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := DecryptFile(encryptedFile, target, key); err != nil {
+			t.Errorf("Decrypting to %s failed: %s", test.description, err)
+			continue
+		}
+
+		fileInfo, err := os.Stat(target)
+		if err != nil {
+			t.Errorf("Decrypting to %s created no file: %s", test.description, err)
+			continue
+		}
+
+		if mode := fileInfo.Mode().Perm(); mode != test.expectedMode {
+			t.Errorf("Decrypting to %s created a file with mode %04o, expected %04o", test.description, mode, test.expectedMode)
+		}
+
+		if content, _ := ioutil.ReadFile(target); string(content) != "secret" {
+			t.Errorf("Decrypting to %s wrote %q, expected \"secret\"", test.description, content)
+		}
+
+		if files, _ := filepath.Glob(filepath.Join(filepath.Dir(target), ".*")); len(files) > 0 {
+			t.Errorf("Decrypting to %s left temporary files: %v", test.description, files)
+		}
+	}
+}
+
+func TestDecryptFileKeepsTheTargetIfDecryptionFails(t *testing.T) {
+
+	directory := t.TempDir()
+	encryptedFile := filepath.Join(directory, "encrypted")
+	target := filepath.Join(directory, "target")
+
+	if err := ioutil.WriteFile(encryptedFile, encrypt(t, []byte("secret"), newTestKey("0123456789abcdef")), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(target, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecryptFile(encryptedFile, target, newTestKey("fedcba9876543210")); err == nil {
+		t.Fatalf("Decrypting with the wrong key succeeded")
+	}
+
+	if content, _ := ioutil.ReadFile(target); string(content) != "old" {
+		t.Errorf("The target contains %q after a failed decryption, expected \"old\"", content)
+	}
+}
+
+func TestKeyMatches(t *testing.T) {
+
+	key := newTestKey("0123456789abcdef")
+	plaintext := bytes.Repeat([]byte("x"), 3*chunkSize)
+	ciphertext := encrypt(t, plaintext, key)
+
+	modifiedFirstChunk := append([]byte{}, ciphertext...)
+	modifiedFirstChunk[headerSize+10] ^= 1
+
+	modifiedLastChunk := append([]byte{}, ciphertext...)
+	modifiedLastChunk[len(modifiedLastChunk)-10] ^= 1
+
+	tests := []struct {
+		name       string
+		content    []byte
+		key        *Key
+		keyMatches bool
+	}{
+		{"the right key", ciphertext, key, true},
+		{"another key", ciphertext, newTestKey("fedcba9876543210"), false},
+		{"another key type", ciphertext, NewPassphraseKey("0123456789abcdef"), false},
+		{"a modified first chunk", modifiedFirstChunk, key, false},
+		{"a modified last chunk", modifiedLastChunk, key, true},
+		{"an empty file", encrypt(t, []byte{}, key), key, true},
+		{"plain text", plaintext, key, false},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "file")
+		if err := ioutil.WriteFile(path, test.content, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if keyMatches, err := KeyMatches(path, test.key); err != nil || keyMatches != test.keyMatches {
+			t.Errorf("KeyMatches(%s) = %v, %v, expected %v", test.name, keyMatches, err, test.keyMatches)
+		}
+	}
+}
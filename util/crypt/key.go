@@ -0,0 +1,138 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package crypt
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/andreaskoch/dotman/util/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// the environment variable which contains the path of the key file
+	KeyFileEnvironmentVariable = "DOTMAN_KEYFILE"
+
+	// the environment variable which contains the passphrase
+	PassphraseEnvironmentVariable = "DOTMAN_PASSPHRASE"
+
+	// the name of the key file in the home directory which is used if no other key is specified
+	DefaultKeyFileName = ".dotman.key"
+)
+
+var (
+	// all passphrases are read with the same reader because a reader
+	// may buffer more than one line of piped input
+	stdinReader = bufio.NewReader(os.Stdin)
+)
+
+// GetKey returns the key from the supplied key file. If no key file is specified the
+// key file or passphrase from the environment or the default key file in the home
+// directory is used. Otherwise the user is asked for a passphrase (twice if the
+// passphrase must be confirmed).
+func GetKey(keyFile string, confirmPassphrase bool) (*Key, error) {
+
+	if keyFile != "" {
+		return NewKeyFileKey(keyFile)
+	}
+
+	if keyFile := os.Getenv(KeyFileEnvironmentVariable); keyFile != "" {
+		return NewKeyFileKey(keyFile)
+	}
+
+	if passphrase := os.Getenv(PassphraseEnvironmentVariable); passphrase != "" {
+		return NewPassphraseKey(passphrase), nil
+	}
+
+	if homeDirectory, err := fs.GetUserHomeDirectory(); err == nil {
+		if keyFile := filepath.Join(homeDirectory, DefaultKeyFileName); fs.FileExists(keyFile) {
+			return NewKeyFileKey(keyFile)
+		}
+	}
+
+	passphrase, err := readPassphrase("Passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+
+	if passphrase == "" {
+		return nil, fmt.Errorf("The passphrase must not be empty.")
+	}
+
+	if confirmPassphrase {
+		confirmation, err := readPassphrase("Repeat the passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+
+		if confirmation != passphrase {
+			return nil, fmt.Errorf("The passphrases do not match.")
+		}
+	}
+
+	return NewPassphraseKey(passphrase), nil
+}
+
+// readPassphrase reads a line from the standard input. The input is
+// not echoed if the standard input is a terminal.
+func readPassphrase(prompt string) (string, error) {
+
+	fmt.Fprint(os.Stderr, prompt)
+
+	if stdinInfo, err := os.Stdin.Stat(); err == nil && stdinInfo.Mode()&os.ModeCharDevice != 0 {
+		if err := setTerminalEcho(false); err == nil {
+			defer func() {
+				setTerminalEcho(true)
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("Unable to read the passphrase. %s", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func setTerminalEcho(enabled bool) error {
+	mode := "-echo"
+	if enabled {
+		mode = "echo"
+	}
+
+	command := exec.Command("stty", mode)
+	command.Stdin = os.Stdin
+	return command.Run()
+}
+
+// A KeyProvider asks for the key when it is needed for the first time
+// and returns the same key afterwards.
+type KeyProvider struct {
+	keyFile           string
+	confirmPassphrase bool
+
+	key *Key
+	err error
+}
+
+func NewKeyProvider(keyFile string, confirmPassphrase bool) *KeyProvider {
+	return &KeyProvider{
+		keyFile:           keyFile,
+		confirmPassphrase: confirmPassphrase,
+	}
+}
+
+func (provider *KeyProvider) Key() (*Key, error) {
+	if provider.key == nil && provider.err == nil {
+		provider.key, provider.err = GetKey(provider.keyFile, provider.confirmPassphrase)
+	}
+
+	return provider.key, provider.err
+}
@@ -0,0 +1,30 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package crypt
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadPassphraseFromPipedInput(t *testing.T) {
+
+	previousReader := stdinReader
+	defer func() { stdinReader = previousReader }()
+
+	stdinReader = bufio.NewReader(strings.NewReader("secret\r\nsecret\n"))
+
+	for _, prompt := range []string{"Passphrase: ", "Repeat the passphrase: "} {
+		passphrase, err := readPassphrase(prompt)
+		if err != nil || passphrase != "secret" {
+			t.Errorf("readPassphrase(%q) = %q, %v, expected \"secret\"", prompt, passphrase, err)
+		}
+	}
+
+	if passphrase, err := readPassphrase("Passphrase: "); err == nil {
+		t.Errorf("readPassphrase returned %q after the end of the input, expected an error", passphrase)
+	}
+}
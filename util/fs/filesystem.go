@@ -57,10 +57,15 @@ func CreateDirectory(path string) bool {
 }
 
 func Copy(source, target string) (success bool, err error) {
+	return CopyWith(source, target, CopyFile)
+}
+
+// CopyWith copies the supplied file or directory using the given function for each file.
+func CopyWith(source, target string, copyFile func(source, target string) (bool, error)) (success bool, err error) {
 
 	// check if the source is a file
 	if IsFile(source) {
-		return CopyFile(source, target)
+		return copyFile(source, target)
 	}
 
 	// the source must be a directory
@@ -76,7 +81,7 @@ func Copy(source, target string) (success bool, err error) {
 		// recurse into the sub-directory
 		if sourceEntry.IsDir() {
 			nestedTargetPath := filepath.Join(target, sourceEntry.Name())
-			if _, err := Copy(sourceEntryPath, nestedTargetPath); err != nil {
+			if _, err := CopyWith(sourceEntryPath, nestedTargetPath, copyFile); err != nil {
 				return false, err // abort if an error occurs
 			}
 
@@ -85,7 +90,7 @@ func Copy(source, target string) (success bool, err error) {
 
 		// copy the file
 		targetFilePath := filepath.Join(target, sourceEntry.Name())
-		if _, err := CopyFile(sourceEntryPath, targetFilePath); err != nil {
+		if _, err := copyFile(sourceEntryPath, targetFilePath); err != nil {
 			return false, err // abort if an error occurs
 		}
 	}
@@ -153,12 +158,74 @@ func CreateFile(filePath string) (success bool, err error) {
 
 	// create the file
 	if _, err := os.Create(filePath); err != nil {
-		return false, fmt.Errorf("Could not create file %q. Error: ", filePath, err)
+		return false, fmt.Errorf("Could not create file %q. Error: %s", filePath, err)
 	}
 
 	return true, nil
 }
 
+// WriteFileAtomically writes the content of the supplied reader to a temporary file next to
+// the given path and then moves it into place, so the path never contains a half-written file.
+// The file gets the supplied permissions; until then it is only readable by the current user.
+// If the path is a symbolic link, the file it points to is replaced (like CopyFile does).
+func WriteFileAtomically(path string, content io.Reader, mode os.FileMode) error {
+
+	// write through symbolic links (e.g. a dotfile which is linked into another repository)
+	if resolvedPath, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolvedPath
+	}
+
+	// make sure the parent directory exists
+	if !CreateDirectory(filepath.Dir(path)) {
+		return fmt.Errorf("Cannot create the directory for the given file %q.", path)
+	}
+
+	// temporary files are created with mode 0600
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	if err := file.Chmod(mode.Perm()); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return nil
+}
+
+// GetFileMode returns the permissions of the supplied file or the default mode if the file doesn't exist.
+func GetFileMode(path string, defaultMode os.FileMode) os.FileMode {
+	if fileInfo, err := os.Stat(path); err == nil {
+		return fileInfo.Mode().Perm()
+	}
+
+	return defaultMode
+}
+
 func PathExists(path string) bool {
 	if strings.TrimSpace(path) == "" {
 		return false
@@ -217,6 +284,12 @@ func IsDirectory(path string) bool {
 	return fileInfo.IsDir()
 }
 
+// IsSameOrInside checks if the supplied path is the same as or inside of the supplied directory.
+func IsSameOrInside(path, directory string) bool {
+	relativePath, err := filepath.Rel(directory, path)
+	return err == nil && relativePath != ".." && !strings.HasPrefix(relativePath, ".."+string(os.PathSeparator))
+}
+
 func GetUserHomeDirectory() (string, error) {
 
 	usr, err := user.Current()
@@ -302,18 +375,23 @@ func GetFileHash(file string) (string, error) {
 }
 
 func DirectoriesAreEqual(source, target string) (directoriesAreEqual bool, filesThatAreDifferent []string, err error) {
+	return DirectoriesAreEqualWith(source, target, FilesAreEqual)
+}
+
+// DirectoriesAreEqualWith compares the supplied directories using the given function for each file.
+func DirectoriesAreEqualWith(source, target string, filesAreEqual func(source, target string) (bool, error)) (directoriesAreEqual bool, filesThatAreDifferent []string, err error) {
 
 	filesThatAreDifferent = make([]string, 0)
 	err = forEachDirectoryEntry(source, func(file os.FileInfo) error {
 
 		subSource := filepath.Join(source, file.Name())
-		subTarget := filepath.Join(source, file.Name())
+		subTarget := filepath.Join(target, file.Name())
 
 		// check if the file is are directory
 		if file.IsDir() {
 
 			// recurse
-			directoriesAreEqual, changedFiles, subDirectoryError := DirectoriesAreEqual(subSource, subTarget)
+			directoriesAreEqual, changedFiles, subDirectoryError := DirectoriesAreEqualWith(subSource, subTarget, filesAreEqual)
 			if subDirectoryError != nil {
 				return subDirectoryError
 			}
@@ -326,8 +404,14 @@ func DirectoriesAreEqual(source, target string) (directoriesAreEqual bool, files
 			return nil
 		}
 
+		// files which don't exist in the target are different
+		if !PathExists(subTarget) {
+			filesThatAreDifferent = append(filesThatAreDifferent, subTarget)
+			return nil
+		}
+
 		// check if the source and target are different
-		sourceAndTargetAreEqual, err := FilesAreEqual(subSource, subTarget)
+		sourceAndTargetAreEqual, err := filesAreEqual(subSource, subTarget)
 		if err != nil {
 			return err
 		}
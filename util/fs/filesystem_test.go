@@ -0,0 +1,93 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsSameOrInside(t *testing.T) {
+
+	tests := []struct {
+		path      string
+		directory string
+		expected  bool
+	}{
+		{"/home/user", "/home/user", true},
+		{"/home/user/.vimrc", "/home/user", true},
+		{"/home/user/.config/nvim", "/home/user", true},
+		{"/home/user/../other", "/home/user", false},
+		{"/home/other", "/home/user", false},
+		{"/home/username", "/home/user", false},
+		{"/home/user/..vimrc", "/home/user", true},
+		{"/home", "/home/user", false},
+		{"/", "/home/user", false},
+		{"/home/user/.vimrc", "/", true},
+	}
+
+	for _, test := range tests {
+		path, directory := filepath.FromSlash(test.path), filepath.FromSlash(test.directory)
+		if result := IsSameOrInside(path, directory); result != test.expected {
+			t.Errorf("IsSameOrInside(%q, %q) = %v, expected %v", test.path, test.directory, result, test.expected)
+		}
+	}
+}
+
+func TestWriteFileAtomically(t *testing.T) {
+
+	directory := t.TempDir()
+	linkedFile := filepath.Join(directory, "repository", "bashrc")
+	if err := os.MkdirAll(filepath.Dir(linkedFile), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(linkedFile, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(directory, ".bashrc")
+	if err := os.Symlink(linkedFile, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path        string
+		writtenPath string
+	}{
+		{filepath.Join(directory, "new", "file"), filepath.Join(directory, "new", "file")},
+		{linkedFile, linkedFile},
+		{link, linkedFile},
+	}
+
+	for _, test := range tests {
+		if err := WriteFileAtomically(test.path, strings.NewReader("new"), 0640); err != nil {
+			t.Errorf("WriteFileAtomically(%q) failed: %s", test.path, err)
+			continue
+		}
+
+		fileInfo, err := os.Lstat(test.writtenPath)
+		if err != nil || !fileInfo.Mode().IsRegular() || fileInfo.Mode().Perm() != 0640 {
+			t.Errorf("WriteFileAtomically(%q) wrote %v (%v), expected a regular file with the mode 0640 at %q", test.path, fileInfo, err, test.writtenPath)
+		}
+
+		if content, err := ioutil.ReadFile(test.writtenPath); err != nil || string(content) != "new" {
+			t.Errorf("WriteFileAtomically(%q) wrote %q (%v) to %q, expected %q", test.path, content, err, test.writtenPath, "new")
+		}
+	}
+
+	// the link is kept
+	if fileInfo, err := os.Lstat(link); err != nil || fileInfo.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("WriteFileAtomically(%q) replaced the symbolic link", link)
+	}
+
+	temporaryFiles, _ := filepath.Glob(filepath.Join(directory, "*", ".*"))
+	if len(temporaryFiles) > 0 {
+		t.Errorf("WriteFileAtomically left temporary files: %v", temporaryFiles)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package glob matches slash-separated paths against glob patterns.
+//
+// "*" matches any sequence of characters except "/", "?" matches a single character
+// except "/", "[...]" matches one of the characters in the brackets ("[!...]" negates
+// the class) and "**" matches any number of directories. A backslash escapes the
+// following character. Every wildcard is a capture group of the regular expression
+// the pattern is converted to (numbered from left to right).
+package glob
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type Glob struct {
+	pattern    string
+	expression *regexp.Regexp
+}
+
+// Compile parses the supplied glob pattern.
+func Compile(pattern string) (*Glob, error) {
+
+	expression, err := toRegularExpression(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a valid glob pattern. %s", pattern, err)
+	}
+
+	compiledExpression, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a valid glob pattern. %s", pattern, err)
+	}
+
+	return &Glob{
+		pattern:    pattern,
+		expression: compiledExpression,
+	}, nil
+}
+
+func (glob *Glob) String() string {
+	return glob.pattern
+}
+
+// Match checks if the supplied slash-separated path matches the pattern.
+func (glob *Glob) Match(path string) bool {
+	return glob.expression.MatchString(path)
+}
+
+// Expression returns the regular expression which matches the same paths as the pattern.
+func (glob *Glob) Expression() *regexp.Regexp {
+	return glob.expression
+}
+
+// IsRecursive checks if the pattern can match paths in sub-directories.
+func (glob *Glob) IsRecursive() bool {
+	return strings.Contains(glob.pattern, "/") || strings.Contains(glob.pattern, "**")
+}
+
+// Find returns the paths (relative to the supplied directory) of all files and directories
+// which match the pattern. The contents of matching directories are not searched.
+func (glob *Glob) Find(directory string) []string {
+	return glob.find(directory, "")
+}
+
+func (glob *Glob) find(directory, relativeDirectory string) []string {
+
+	matches := make([]string, 0)
+
+	entries, err := ioutil.ReadDir(filepath.Join(directory, filepath.FromSlash(relativeDirectory)))
+	if err != nil {
+		return matches
+	}
+
+	for _, entry := range entries {
+
+		relativePath := path.Join(relativeDirectory, entry.Name())
+		if glob.Match(relativePath) {
+			matches = append(matches, relativePath)
+			continue
+		}
+
+		if entry.IsDir() && glob.IsRecursive() {
+			matches = append(matches, glob.find(directory, relativePath)...)
+		}
+	}
+
+	return matches
+}
+
+// toRegularExpression converts the supplied glob pattern into a regular expression.
+func toRegularExpression(pattern string) (string, error) {
+
+	var expression strings.Builder
+	expression.WriteString("^")
+
+	characters := []rune(pattern)
+	for index := 0; index < len(characters); index++ {
+		character := characters[index]
+
+		switch character {
+
+		case '*':
+			if index+1 < len(characters) && characters[index+1] == '*' {
+
+				// "**/" matches any number of directories (including none)
+				if index+2 < len(characters) && characters[index+2] == '/' && (index == 0 || characters[index-1] == '/') {
+					expression.WriteString("((?:.*/)?)")
+					index += 2
+					continue
+				}
+
+				// "**" at the end matches everything
+				expression.WriteString("(.*)")
+				index++
+				continue
+			}
+
+			expression.WriteString("([^/]*)")
+
+		case '?':
+			expression.WriteString("([^/])")
+
+		case '[':
+			end := index + 1
+			if end < len(characters) && (characters[end] == '!' || characters[end] == '^') {
+				end++
+			}
+
+			if end < len(characters) && characters[end] == ']' {
+				end++
+			}
+
+			for end < len(characters) && characters[end] != ']' {
+				end++
+			}
+
+			if end >= len(characters) {
+				return "", fmt.Errorf("The character class at position %d is not closed.", index+1)
+			}
+
+			class := characters[index+1 : end]
+			expression.WriteString("([")
+			if len(class) > 0 && (class[0] == '!' || class[0] == '^') {
+				expression.WriteString("^/")
+				class = class[1:]
+			}
+
+			for _, classCharacter := range class {
+				if classCharacter == '\\' || classCharacter == '[' || classCharacter == ']' || classCharacter == '^' {
+					expression.WriteString(`\`)
+				}
+
+				expression.WriteRune(classCharacter)
+			}
+
+			expression.WriteString("])")
+			index = end
+
+		case '\\':
+			if index+1 >= len(characters) {
+				return "", fmt.Errorf("The pattern must not end with a backslash.")
+			}
+
+			index++
+			expression.WriteString(regexp.QuoteMeta(string(characters[index])))
+
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(character)))
+
+		}
+	}
+
+	expression.WriteString("$")
+	return expression.String(), nil
+}
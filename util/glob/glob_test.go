@@ -0,0 +1,141 @@
+// Copyright 2013 Andreas Koch. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package glob
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+
+	tests := []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		{"*.sh", "script.sh", true},
+		{"*.sh", "dir/script.sh", false},
+		{"*.sh", "script.bash", false},
+		{"*", ".hidden", true},
+		{".*", ".hidden", true},
+		{".*", "visible", false},
+		{"?.txt", "a.txt", true},
+		{"?.txt", "ab.txt", false},
+		{"?", "/", false},
+		{"[abc].txt", "b.txt", true},
+		{"[abc].txt", "d.txt", false},
+		{"[!abc].txt", "d.txt", true},
+		{"[!abc].txt", "a.txt", false},
+		{"[]].txt", "].txt", true},
+		{"**/*.sh", "script.sh", true},
+		{"**/*.sh", "a/b/script.sh", true},
+		{"**/*.sh", "a/b/script.bash", false},
+		{"a/**/z", "a/z", true},
+		{"a/**/z", "a/b/c/z", true},
+		{"a/**", "a/b/c", true},
+		{"bundle/*/doc", "bundle/vim-fugitive/doc", true},
+		{"bundle/*/doc", "bundle/a/b/doc", false},
+		{`\*.txt`, "*.txt", true},
+		{`\*.txt`, "a.txt", false},
+		{"a+b(c).txt", "a+b(c).txt", true},
+		{"exact", "exact", true},
+		{"exact", "exactly", false},
+	}
+
+	for _, test := range tests {
+		glob, err := Compile(test.pattern)
+		if err != nil {
+			t.Errorf("Compile(%q) failed: %s", test.pattern, err)
+			continue
+		}
+
+		if matches := glob.Match(test.path); matches != test.matches {
+			t.Errorf("%q matches %q: %v, expected %v", test.pattern, test.path, matches, test.matches)
+		}
+	}
+}
+
+func TestGlobCaptureGroups(t *testing.T) {
+
+	tests := []struct {
+		pattern  string
+		path     string
+		expected []string
+	}{
+		{"*.symlink", "bashrc.symlink", []string{"bashrc"}},
+		{"*/*.conf", "app/main.conf", []string{"app", "main"}},
+		{"**/*.sh", "a/b/run.sh", []string{"a/b/", "run"}},
+		{"?-[0-9].txt", "a-1.txt", []string{"a", "1"}},
+		{"exact", "exact", []string{}},
+	}
+
+	for _, test := range tests {
+		glob, err := Compile(test.pattern)
+		if err != nil {
+			t.Errorf("Compile(%q) failed: %s", test.pattern, err)
+			continue
+		}
+
+		submatches := glob.Expression().FindStringSubmatch(test.path)
+		if submatches == nil {
+			t.Errorf("%q doesn't match %q", test.pattern, test.path)
+			continue
+		}
+
+		if groups := submatches[1:]; !reflect.DeepEqual(groups, test.expected) {
+			t.Errorf("The capture groups of %q in %q are %q, expected %q", test.pattern, test.path, groups, test.expected)
+		}
+	}
+}
+
+func TestInvalidGlobs(t *testing.T) {
+	for _, pattern := range []string{"[abc", `trailing\`, "[!"} {
+		if _, err := Compile(pattern); err == nil {
+			t.Errorf("Compile(%q) succeeded, expected an error", pattern)
+		}
+	}
+}
+
+func TestGlobFind(t *testing.T) {
+
+	directory := t.TempDir()
+	for _, path := range []string{"a.sh", "b.txt", "bin/c.sh", "bin/deep/d.sh", "doc/e.sh/f.txt"} {
+		path = filepath.Join(directory, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := ioutil.WriteFile(path, []byte(path), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"*.sh", []string{"a.sh"}},
+		{"*", []string{"a.sh", "b.txt", "bin", "doc"}},
+		{"**/*.sh", []string{"a.sh", "bin/c.sh", "bin/deep/d.sh", "doc/e.sh"}},
+		{"bin/*", []string{"bin/c.sh", "bin/deep"}},
+		{"*.none", []string{}},
+	}
+
+	for _, test := range tests {
+		glob, err := Compile(test.pattern)
+		if err != nil {
+			t.Errorf("Compile(%q) failed: %s", test.pattern, err)
+			continue
+		}
+
+		matches := glob.Find(directory)
+		sort.Strings(matches)
+		if !reflect.DeepEqual(matches, test.expected) {
+			t.Errorf("Find(%q) = %q, expected %q", test.pattern, matches, test.expected)
+		}
+	}
+}